@@ -123,6 +123,7 @@ func (c *Ctx) Accepts(offers ...string) string {
 		return offers[0]
 	}
 
+	accept := header
 	spec, commaPos := "", 0
 	for len(header) > 0 && commaPos != -1 {
 		commaPos = strings.IndexByte(header, ',')
@@ -131,39 +132,31 @@ func (c *Ctx) Accepts(offers ...string) string {
 		} else {
 			spec = utils.TrimLeft(header, ' ')
 		}
+		refusedSpec := false
 		if factorSign := strings.IndexByte(spec, ';'); factorSign != -1 {
+			refusedSpec = refused(spec[factorSign+1:])
 			spec = spec[:factorSign]
 		}
+		if commaPos != -1 {
+			header = header[commaPos+1:]
+		}
+		// Skip empty segments, e.g. from "text/html, , application/json",
+		// and types the client refuses with q=0
+		if len(spec) == 0 || refusedSpec {
+			continue
+		}
 
-		var mimetype string
 		for _, offer := range offers {
 			if len(offer) == 0 {
 				continue
-				// Accept: */*
-			} else if spec == "*/*" {
-				return offer
-			}
-
-			if strings.IndexByte(offer, '/') != -1 {
-				mimetype = offer // MIME type
-			} else {
-				mimetype = utils.GetMIME(offer) // extension
 			}
-
-			if spec == mimetype {
-				// Accept: <MIME_type>/<MIME_subtype>
-				return offer
-			}
-
-			s := strings.IndexByte(mimetype, '/')
-			// Accept: <MIME_type>/*
-			if strings.HasPrefix(spec, mimetype[:s]) && (spec[s:] == "/*" || mimetype[s:] == "/*") {
+			mimetype := offerMIME(offer)
+			// A refused range at least as specific as the matching one
+			// excludes the offer, e.g. "application/json;q=0, */*"
+			if level := acceptLevel(spec, mimetype); level > 0 && !refusedMIME(accept, mimetype, level) {
 				return offer
 			}
 		}
-		if commaPos != -1 {
-			header = header[commaPos+1:]
-		}
 	}
 
 	return ""
@@ -372,13 +365,15 @@ func (c *Ctx) Response() *fasthttp.Response {
 }
 
 // Format performs content-negotiation on the Accept HTTP header.
-// It uses Accepts to select a proper format.
+// It uses Accepts to select a proper format and adds Accept to the Vary header.
 // If the header is not specified or there is no proper format, text/plain is used.
+// HEAD requests receive the same headers as GET, the body is omitted by the server.
 func (c *Ctx) Format(body interface{}) error {
 	// Get accepted content type
 	accept := c.Accepts("html", "json", "txt", "xml")
 	// Set accepted content type
 	c.Type(accept)
+	c.Vary(HeaderAccept)
 	// Type convert provided body
 	var b string
 	switch val := body.(type) {
@@ -95,6 +95,153 @@ func Test_Ctx_Accepts_Wildcard(t *testing.T) {
 	utils.AssertEqual(t, "xml", c.Accepts("xml"))
 }
 
+// go test -run Test_Ctx_Accepts_Safari_Image
+func Test_Ctx_Accepts_Safari_Image(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// sent by older Safari versions for image requests
+	c.Request().Header.Set(HeaderAccept, "image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5")
+	utils.AssertEqual(t, "image/webp", c.Accepts("image/webp"))
+	utils.AssertEqual(t, "webp", c.Accepts("webp"))
+	// image/* is listed before */*, so it decides over earlier offers
+	utils.AssertEqual(t, "image/webp", c.Accepts("application/json", "image/webp"))
+	utils.AssertEqual(t, "png", c.Accepts("webp", "png"))
+	utils.AssertEqual(t, "application/json", c.Accepts("application/json"))
+}
+
+// go test -run Test_Ctx_Accepts_Subtype_Near_Miss
+func Test_Ctx_Accepts_Subtype_Near_Miss(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// subtypes only match exactly or through a wildcard, never by prefix
+	for _, pair := range [][2]string{
+		{"application/json-seq", "application/json"},
+		{"application/json", "application/json-seq"},
+		{"application/json", "application/jsonx"},
+		{"application/ld+json", "application/json"},
+		{"application/json", "application/ld+json"},
+		{"text/html", "text/htm"},
+		{"text/htm", "text/html"},
+		{"imagex/*", "image/png"},
+		{"textual/*", "text/plain"},
+	} {
+		c.Request().Header.Set(HeaderAccept, pair[0])
+		utils.AssertEqual(t, "", c.Accepts(pair[1]), pair[0]+" accepts "+pair[1])
+	}
+}
+
+// go test -run Test_Ctx_Accepts_Low_Wildcard
+func Test_Ctx_Accepts_Low_Wildcard(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// browsers append a barely acceptable */* to their preferred types
+	c.Request().Header.Set(HeaderAccept, "text/html, */*;q=0.01")
+	utils.AssertEqual(t, "application/json", c.Accepts("application/json"))
+	utils.AssertEqual(t, "json", c.Accepts("json"))
+	// text/html is listed first, so it decides over earlier offers
+	utils.AssertEqual(t, "html", c.Accepts("json", "html"))
+}
+
+// go test -run Test_Ctx_Accepts_Offer_Params
+func Test_Ctx_Accepts_Offer_Params(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// offers are returned verbatim, including their parameters
+	c.Request().Header.Set(HeaderAccept, "text/html, application/json;q=0.9")
+	utils.AssertEqual(t, "text/html; charset=utf-8", c.Accepts("text/html; charset=utf-8"))
+	utils.AssertEqual(t, "application/json;charset=utf-8", c.Accepts("text/plain", "application/json;charset=utf-8"))
+	utils.AssertEqual(t, "", c.Accepts("text/plain;charset=utf-8"))
+	c.Request().Header.Set(HeaderAccept, "text/*")
+	utils.AssertEqual(t, "text/plain;charset=utf-8", c.Accepts("text/plain;charset=utf-8"))
+}
+
+// go test -run Test_Ctx_Accepts_Invalid_Wildcard
+func Test_Ctx_Accepts_Invalid_Wildcard(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// a wildcard type requires a wildcard subtype
+	for _, accept := range []string{"*/json", "*/json;q=0.9", "*/", "*"} {
+		c.Request().Header.Set(HeaderAccept, accept)
+		utils.AssertEqual(t, "", c.Accepts("application/json", "json", "text/html", "text/*"), accept)
+	}
+	c.Request().Header.Set(HeaderAccept, "*/json, text/html")
+	utils.AssertEqual(t, "text/html", c.Accepts("application/json", "text/html"))
+}
+
+// go test -run Test_Ctx_Accepts_Empty_Segments
+func Test_Ctx_Accepts_Empty_Segments(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	for _, accept := range []string{
+		"text/html, , application/json",
+		", text/html, application/json",
+		"text/html, application/json,",
+		"text/html,   ,application/json, ",
+		";q=0.5, text/html, application/json",
+	} {
+		c.Request().Header.Set(HeaderAccept, accept)
+		utils.AssertEqual(t, "application/json", c.Accepts("application/json"), accept)
+		utils.AssertEqual(t, "text/html", c.Accepts("text/html", "application/json"), accept)
+		utils.AssertEqual(t, "", c.Accepts("image/png", "unknownext"), accept)
+	}
+}
+
+// go test -run Test_Ctx_Accepts_Leading_Wildcard
+func Test_Ctx_Accepts_Leading_Wildcard(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	for _, accept := range []string{"*/*, application/json", "application/json, */*"} {
+		c.Request().Header.Set(HeaderAccept, accept)
+		utils.AssertEqual(t, "application/json", c.Accepts("application/json"), accept)
+		utils.AssertEqual(t, "json", c.Accepts("json"), accept)
+	}
+}
+
+// go test -run Test_Ctx_Accepts_Zero_Quality
+func Test_Ctx_Accepts_Zero_Quality(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	// a refused wildcard doesn't accept everything
+	c.Request().Header.Set(HeaderAccept, "*/*;q=0, text/html")
+	utils.AssertEqual(t, "", c.Accepts("application/json"))
+	utils.AssertEqual(t, "text/html", c.Accepts("application/json", "text/html"))
+	// refused types are not acceptable
+	for _, accept := range []string{"application/json;q=0", "application/json; q=0.0", "application/json;level=1;Q=0.000"} {
+		c.Request().Header.Set(HeaderAccept, accept)
+		utils.AssertEqual(t, "", c.Accepts("application/json"), accept)
+		utils.AssertEqual(t, "", c.Accepts("json"), accept)
+	}
+	// a refused type isn't accepted by a less specific range
+	for _, accept := range []string{"application/json;q=0, */*", "*/*, application/json;q=0", "application/*, application/json;q=0"} {
+		c.Request().Header.Set(HeaderAccept, accept)
+		utils.AssertEqual(t, "", c.Accepts("application/json"), accept)
+		utils.AssertEqual(t, "application/xml", c.Accepts("application/json", "application/xml"), accept)
+	}
+	// but by a more specific one
+	c.Request().Header.Set(HeaderAccept, "text/*;q=0, text/html")
+	utils.AssertEqual(t, "text/html", c.Accepts("text/html"))
+	utils.AssertEqual(t, "", c.Accepts("text/plain"))
+	// other specs still match
+	c.Request().Header.Set(HeaderAccept, "text/html;q=0.5, application/json;q=0")
+	utils.AssertEqual(t, "text/html", c.Accepts("application/json", "text/html"))
+}
+
 // go test -run Test_Ctx_AcceptsCharsets
 func Test_Ctx_AcceptsCharsets(t *testing.T) {
 	t.Parallel()
@@ -534,6 +681,36 @@ func Test_Ctx_Format(t *testing.T) {
 	utils.AssertEqual(t, `Hello, World!`, string(c.Response().Body()))
 }
 
+// go test -run Test_Ctx_Format_Head
+func Test_Ctx_Format_Head(t *testing.T) {
+	t.Parallel()
+	app := New()
+	app.Get("/", func(c *Ctx) error {
+		return c.Format("Hello, World!")
+	})
+
+	for _, accept := range []string{MIMETextHTML, MIMEApplicationJSON, MIMEApplicationXML, MIMETextPlain} {
+		req := httptest.NewRequest(MethodGet, "/", nil)
+		req.Header.Set(HeaderAccept, accept)
+		getResp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+
+		req = httptest.NewRequest(MethodHead, "/", nil)
+		req.Header.Set(HeaderAccept, accept)
+		headResp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+
+		utils.AssertEqual(t, StatusOK, headResp.StatusCode)
+		utils.AssertEqual(t, getResp.Header.Get(HeaderContentType), headResp.Header.Get(HeaderContentType))
+		utils.AssertEqual(t, HeaderAccept, getResp.Header.Get(HeaderVary))
+		utils.AssertEqual(t, getResp.Header.Get(HeaderVary), headResp.Header.Get(HeaderVary))
+
+		body, err := ioutil.ReadAll(headResp.Body)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, 0, len(body))
+	}
+}
+
 // go test -v -run=^$ -bench=Benchmark_Ctx_Format -benchmem -count=4
 func Benchmark_Ctx_Format(b *testing.B) {
 	app := New()
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -264,6 +265,83 @@ func getOffer(header string, offers ...string) string {
 	return ""
 }
 
+// refused reports whether the parameters of an Accept spec carry a quality
+// of zero, e.g. ";q=0", which marks the spec as not acceptable
+func refused(params string) bool {
+	for len(params) > 0 {
+		param := params
+		if semicolon := strings.IndexByte(params, ';'); semicolon != -1 {
+			param, params = params[:semicolon], params[semicolon+1:]
+		} else {
+			params = ""
+		}
+		param = utils.Trim(param, ' ')
+		if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		return err == nil && q == 0
+	}
+	return false
+}
+
+// offerMIME returns the MIME type of an Accepts offer, extensions are looked
+// up and parameters are stripped as they are not compared
+func offerMIME(offer string) string {
+	if strings.IndexByte(offer, '/') == -1 {
+		return utils.GetMIME(offer) // extension
+	}
+	if paramSign := strings.IndexByte(offer, ';'); paramSign != -1 {
+		return utils.TrimRight(offer[:paramSign], ' ')
+	}
+	return offer
+}
+
+// acceptLevel reports how specific the media range of an Accept spec
+// matches the mimetype: 3 for the type itself, 2 for a subtype wildcard,
+// 1 for */* and 0 if it doesn't match
+func acceptLevel(spec, mimetype string) int {
+	if spec == "*/*" {
+		// Accept: */*
+		return 1
+	} else if spec == mimetype {
+		// Accept: <MIME_type>/<MIME_subtype>
+		return 3
+	}
+	s := strings.IndexByte(mimetype, '/')
+	// Accept: <MIME_type>/*
+	if s != -1 && strings.HasPrefix(spec, mimetype[:s]) && (spec[s:] == "/*" || mimetype[s:] == "/*") {
+		return 2
+	}
+	return 0
+}
+
+// refusedMIME reports whether the Accept header refuses the mimetype with
+// q=0 in a media range at least as specific as level
+func refusedMIME(header, mimetype string, level int) bool {
+	// Only specs with parameters can be refused
+	if strings.IndexByte(header, ';') == -1 {
+		return false
+	}
+	spec, commaPos := "", 0
+	for len(header) > 0 && commaPos != -1 {
+		commaPos = strings.IndexByte(header, ',')
+		if commaPos != -1 {
+			spec, header = header[:commaPos], header[commaPos+1:]
+		} else {
+			spec = header
+		}
+		factorSign := strings.IndexByte(spec, ';')
+		if factorSign == -1 || !refused(spec[factorSign+1:]) {
+			continue
+		}
+		if acceptLevel(utils.Trim(spec[:factorSign], ' '), mimetype) >= level {
+			return true
+		}
+	}
+	return false
+}
+
 func matchEtag(s string, etag string) bool {
 	if s == etag || s == "W/"+etag || "W/"+s == etag {
 		return true
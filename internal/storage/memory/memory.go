@@ -11,6 +11,7 @@ type Storage struct {
 	db         map[string]entry
 	gcInterval time.Duration
 	done       chan struct{}
+	onGC       func(removed, scanned int, d time.Duration)
 }
 
 type entry struct {
@@ -101,14 +102,33 @@ func (s *Storage) gc() {
 		case <-s.done:
 			return
 		case t := <-ticker.C:
-			now := t.Unix()
-			s.mux.Lock()
-			for id, v := range s.db {
-				if v.expiry != 0 && v.expiry < now {
-					delete(s.db, id)
-				}
-			}
-			s.mux.Unlock()
+			s.sweep(t.Unix())
 		}
 	}
 }
+
+// OnGC sets a hook that is called after every garbage collection sweep
+// with the number of removed and scanned entries and the sweep duration
+func (s *Storage) OnGC(fn func(removed, scanned int, d time.Duration)) {
+	s.mux.Lock()
+	s.onGC = fn
+	s.mux.Unlock()
+}
+
+func (s *Storage) sweep(now int64) {
+	start := time.Now()
+	s.mux.Lock()
+	removed, scanned := 0, len(s.db)
+	for id, v := range s.db {
+		if v.expiry != 0 && v.expiry < now {
+			delete(s.db, id)
+			removed++
+		}
+	}
+	onGC := s.onGC
+	s.mux.Unlock()
+
+	if onGC != nil {
+		onGC(removed, scanned, time.Since(start))
+	}
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// go test -run Test_Storage_OnGC -v -race
+func Test_Storage_OnGC(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	var removed, scanned int
+	store.OnGC(func(r, s int, d time.Duration) {
+		removed, scanned = r, s
+		utils.AssertEqual(t, true, d >= 0)
+	})
+
+	utils.AssertEqual(t, nil, store.Set("live", []byte("1"), time.Hour))
+	utils.AssertEqual(t, nil, store.Set("forever", []byte("2"), 0))
+	utils.AssertEqual(t, nil, store.Set("expired1", []byte("3"), -time.Minute))
+	utils.AssertEqual(t, nil, store.Set("expired2", []byte("4"), -time.Minute))
+
+	store.sweep(time.Now().Unix())
+	utils.AssertEqual(t, 2, removed)
+	utils.AssertEqual(t, 4, scanned)
+
+	val, err := store.Get("live")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("1"), val)
+
+	// a nil hook is fine
+	store.OnGC(nil)
+	store.sweep(time.Now().Unix())
+}
@@ -0,0 +1,316 @@
+package negotiate
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// ContextKey is the key used to store the negotiated type in the locals
+const ContextKey = "negotiate"
+
+// LanguageKey is the key used to store the negotiated language in the locals
+const LanguageKey = "negotiate_language"
+
+// Produces creates a middleware handler that negotiates the Accept header
+// against the given types. Unacceptable requests are forwarded to the
+// ErrorHandler with fiber.ErrNotAcceptable, otherwise the negotiated
+// type is stored in the locals under ContextKey.
+func Produces(types ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// The response depends on the Accept header, even if it's a 406
+		c.Vary(fiber.HeaderAccept)
+		accept := c.Accepts(types...)
+		if accept == "" {
+			return fiber.ErrNotAcceptable
+		}
+
+		c.Locals(ContextKey, accept)
+
+		// Continue stack
+		return c.Next()
+	}
+}
+
+// Policy decides what Resolve returns if none of the offers is acceptable
+type Policy struct {
+	serveDefault bool
+	defaultType  string
+}
+
+// Policy406 makes Resolve fail with fiber.ErrNotAcceptable
+var Policy406 = Policy{}
+
+// PolicyDefault makes Resolve serve defaultType instead
+func PolicyDefault(defaultType string) Policy {
+	return Policy{serveDefault: true, defaultType: defaultType}
+}
+
+// Resolve negotiates the Accept header value against the provided offers
+// like Ctx.Accepts and applies the policy if none of them is acceptable,
+// so the handlers of an API decide the same way.
+func Resolve(accept string, policy Policy, provided ...string) (string, error) {
+	if offer := accepts(accept, provided...); offer != "" {
+		return offer, nil
+	}
+	if policy.serveDefault {
+		return policy.defaultType, nil
+	}
+	return "", fiber.ErrNotAcceptable
+}
+
+// DefaultOffer negotiates the Accept header value against the provided
+// offers like Ctx.Accepts. Without an Accept header the first offer is
+// returned and defaulted is true, e.g. to log that the default
+// representation was served. It returns "" if no offer is acceptable.
+func DefaultOffer(accept string, provided ...string) (offer string, defaulted bool) {
+	if strings.TrimSpace(accept) == "" {
+		if len(provided) == 0 {
+			return "", false
+		}
+		return provided[0], true
+	}
+	return accepts(accept, provided...), false
+}
+
+var (
+	acceptsOnce sync.Once
+	acceptsApp  *fiber.App
+)
+
+// accepts runs Ctx.Accepts for an Accept header value outside of a request
+func accepts(accept string, offers ...string) string {
+	acceptsOnce.Do(func() {
+		acceptsApp = fiber.New()
+	})
+	c := acceptsApp.AcquireCtx(&fasthttp.RequestCtx{})
+	defer acceptsApp.ReleaseCtx(c)
+	c.Request().Header.Set(fiber.HeaderAccept, accept)
+	return c.Accepts(offers...)
+}
+
+// Languages creates a middleware handler that negotiates the Accept-Language
+// header against the given languages. Tags are compared in their canonical
+// casing, so "EN-us" matches "en-US". The negotiated language is stored in
+// the locals under LanguageKey and set as Content-Language header in its
+// canonical casing. Languages refused with q=0 are skipped, if none of them
+// is acceptable the first language the client didn't refuse is used.
+func Languages(langs ...string) fiber.Handler {
+	// Normalize the offers once
+	normalized := make([]string, len(langs))
+	for i, lang := range langs {
+		normalized[i] = normalizeTag(lang)
+	}
+
+	return func(c *fiber.Ctx) error {
+		c.Vary(fiber.HeaderAcceptLanguage)
+		if len(normalized) > 0 {
+			header := c.Get(fiber.HeaderAcceptLanguage)
+			lang := normalized[0]
+			if i := acceptsLanguages(header, normalized); i != -1 {
+				lang = normalized[i]
+			} else {
+				// Fall back to the first language the client didn't refuse
+				for _, offer := range normalized {
+					if !refusedLanguage(header, offer, 1) {
+						lang = offer
+						break
+					}
+				}
+			}
+			c.Set(fiber.HeaderContentLanguage, lang)
+			c.Locals(LanguageKey, lang)
+		}
+
+		// Continue stack
+		return c.Next()
+	}
+}
+
+// acceptsLanguages works like Ctx.AcceptsLanguages, but compares the
+// normalized tags and returns the index of the offer, or -1 if none of
+// them is acceptable. Languages refused with q=0 are never returned.
+func acceptsLanguages(header string, normalized []string) int {
+	if len(normalized) == 0 {
+		return -1
+	} else if header == "" {
+		return 0
+	}
+
+	for _, spec := range strings.Split(header, ",") {
+		refusedSpec := false
+		if factorSign := strings.IndexByte(spec, ';'); factorSign != -1 {
+			refusedSpec = refused(spec[factorSign+1:])
+			spec = spec[:factorSign]
+		}
+		spec = utils.Trim(spec, ' ')
+		if len(spec) == 0 || refusedSpec {
+			continue
+		}
+		// has star suffix
+		if spec[len(spec)-1] == '*' {
+			for i, offer := range normalized {
+				if !refusedLanguage(header, offer, 0) {
+					return i
+				}
+			}
+			continue
+		}
+		spec = normalizeTag(spec)
+		for i, offer := range normalized {
+			if strings.HasPrefix(spec, offer) && !refusedLanguage(header, offer, len(offer)) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// refusedLanguage reports whether the Accept-Language header refuses the
+// normalized tag with q=0 in a range at least length long, e.g. "de;q=0"
+// refuses "de" and "de-CH". A length of 0 stands for a tag accepted by a
+// wildcard, which "*;q=0" refuses as well.
+func refusedLanguage(header, tag string, length int) bool {
+	// Only ranges with parameters can be refused
+	if strings.IndexByte(header, ';') == -1 {
+		return false
+	}
+	for _, spec := range strings.Split(header, ",") {
+		factorSign := strings.IndexByte(spec, ';')
+		if factorSign == -1 || !refused(spec[factorSign+1:]) {
+			continue
+		}
+		spec = utils.Trim(spec[:factorSign], ' ')
+		if spec == "*" {
+			if length == 0 {
+				return true
+			}
+			continue
+		}
+		spec = normalizeTag(spec)
+		if len(spec) >= length && (tag == spec || strings.HasPrefix(tag, spec+"-")) {
+			return true
+		}
+	}
+	return false
+}
+
+// refused reports whether the parameters of a header spec carry a quality
+// of zero, e.g. ";q=0", like Ctx.Accepts checks it
+func refused(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		param = utils.Trim(param, ' ')
+		if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		return err == nil && q == 0
+	}
+	return false
+}
+
+// irregularTags are the grandfathered tags of RFC 5646 that don't follow
+// the subtag rules, private use and "i-" tags are detected by their prefix
+var irregularTags = map[string]bool{
+	"en-gb-oed": true,
+	"sgn-be-fr": true,
+	"sgn-be-nl": true,
+	"sgn-ch-de": true,
+}
+
+// normalizeTag returns the canonical casing of a language tag: lowercase
+// language, titlecase script and uppercase region. Subtags after a
+// singleton are lowercased, irregular tags are returned unchanged.
+func normalizeTag(tag string) string {
+	lower := utils.ToLower(tag)
+	if irregularTags[lower] || strings.HasPrefix(lower, "i-") || strings.HasPrefix(lower, "x-") {
+		return tag
+	}
+
+	subtags := strings.Split(lower, "-")
+	for i := 1; i < len(subtags); i++ {
+		subtag := subtags[i]
+		switch {
+		case len(subtag) == 1:
+			// extensions and private use stay lowercase
+			return strings.Join(subtags, "-")
+		case len(subtag) == 4 && isAlpha(subtag):
+			subtags[i] = utils.ToUpper(subtag[:1]) + subtag[1:]
+		case len(subtag) == 2 && isAlpha(subtag):
+			subtags[i] = utils.ToUpper(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+// isAlpha reports whether the lowercase subtag only contains letters
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyContentType sets the Content-Type response header to the negotiated
+// offer. MIME types are used verbatim including their parameters, other
+// offers are treated as file extensions. If a charset is passed, it is
+// added to text types that don't specify one, e.g. "text/html" becomes
+// "text/html; charset=utf-8".
+func ApplyContentType(c *fiber.Ctx, chosen string, charset ...string) {
+	mimetype := chosen
+	if strings.IndexByte(chosen, '/') == -1 {
+		mimetype = utils.GetMIME(chosen)
+	}
+	if len(charset) > 0 && strings.HasPrefix(mimetype, "text/") && !strings.Contains(utils.ToLower(mimetype), "charset=") {
+		mimetype += "; charset=" + charset[0]
+	}
+	c.Set(fiber.HeaderContentType, mimetype)
+}
+
+// Unmatched returns the concrete MIME types of the Accept header that none
+// of the provided offers satisfy, e.g. to explain a 406 response. Wildcard
+// types are left out. Offers without a slash are treated as file extensions.
+func Unmatched(accept string, provided ...string) []string {
+	var unmatched []string
+	for _, spec := range strings.Split(accept, ",") {
+		if paramSign := strings.IndexByte(spec, ';'); paramSign != -1 {
+			spec = spec[:paramSign]
+		}
+		spec = utils.Trim(spec, ' ')
+		slash := strings.IndexByte(spec, '/')
+		if slash <= 0 || slash == len(spec)-1 || strings.IndexByte(spec, '*') != -1 {
+			continue
+		}
+		if !satisfies(spec, provided) {
+			unmatched = append(unmatched, spec)
+		}
+	}
+	return unmatched
+}
+
+// satisfies reports whether one of the offers matches the concrete type
+func satisfies(spec string, offers []string) bool {
+	for _, offer := range offers {
+		mimetype := offer
+		if strings.IndexByte(offer, '/') == -1 {
+			mimetype = utils.GetMIME(offer)
+		} else if paramSign := strings.IndexByte(mimetype, ';'); paramSign != -1 {
+			mimetype = utils.TrimRight(mimetype[:paramSign], ' ')
+		}
+		if mimetype == spec {
+			return true
+		}
+		// Offer: <MIME_type>/*
+		if strings.HasSuffix(mimetype, "/*") && strings.HasPrefix(spec, mimetype[:len(mimetype)-1]) {
+			return true
+		}
+	}
+	return false
+}
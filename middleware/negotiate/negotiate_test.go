@@ -0,0 +1,273 @@
+package negotiate
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// go test -run Test_Negotiate_Produces
+func Test_Negotiate_Produces(t *testing.T) {
+	t.Parallel()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.Status(fiber.StatusNotAcceptable).SendString("custom: " + err.Error())
+		},
+	})
+
+	api := app.Group("/api", Produces("json", "xml"))
+	api.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(ContextKey).(string))
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api", nil)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationXML)
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+	utils.AssertEqual(t, fiber.HeaderAccept, resp.Header.Get(fiber.HeaderVary))
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "xml", string(body))
+
+	// without an Accept header the first type is used
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/api", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "json", string(body))
+
+	req = httptest.NewRequest(fiber.MethodGet, "/api", nil)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMETextHTML)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, fiber.StatusNotAcceptable, resp.StatusCode)
+	utils.AssertEqual(t, fiber.HeaderAccept, resp.Header.Get(fiber.HeaderVary))
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "custom: Not Acceptable", string(body))
+}
+
+// go test -run Test_Negotiate_Languages
+func Test_Negotiate_Languages(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(Languages("en-US", "de", "fr"))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(LanguageKey).(string))
+	})
+
+	testCases := []struct {
+		accept string
+		lang   string
+	}{
+		{"de-CH;q=0.9, fr;q=0.8", "de"},
+		{"fr", "fr"},
+		{"es", "en-US"},
+		{"", "en-US"},
+		{"EN-us", "en-US"},
+		{"FR-ca, de", "fr"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if tc.accept != "" {
+			req.Header.Set(fiber.HeaderAcceptLanguage, tc.accept)
+		}
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+		utils.AssertEqual(t, fiber.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, tc.lang, resp.Header.Get(fiber.HeaderContentLanguage), tc.accept)
+		utils.AssertEqual(t, fiber.HeaderAcceptLanguage, resp.Header.Get(fiber.HeaderVary))
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, tc.lang, string(body))
+	}
+}
+
+// go test -run Test_Negotiate_Languages_Refused
+func Test_Negotiate_Languages_Refused(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(Languages("de", "en", "fr"))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(LanguageKey).(string))
+	})
+
+	for accept, lang := range map[string]string{
+		"de;q=0, en":     "en",
+		"de;q=0, *":      "en",
+		"*, DE;q=0.0":    "en",
+		"de-CH;q=0, de":  "de",
+		"de;q=0, de-CH":  "en",
+		"de;q=0":         "en",
+		"es, *;q=0":      "de",
+		"de;q=0, en;q=0": "fr",
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAcceptLanguage, accept)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+		utils.AssertEqual(t, lang, resp.Header.Get(fiber.HeaderContentLanguage), accept)
+	}
+}
+
+// go test -run Test_Negotiate_Languages_Normalize
+func Test_Negotiate_Languages_Normalize(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(Languages("EN-us", "zh-Hant-TW", "zh-hans"))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(LanguageKey).(string))
+	})
+
+	// the canonical tag is emitted, not the offer as it was spelled
+	for accept, lang := range map[string]string{
+		"ZH-HANT-tw":         "zh-Hant-TW",
+		"zh-Hant-TW;q=0.9":   "zh-Hant-TW",
+		"zh-HANS-CN, zh;q=1": "zh-Hans",
+		"en-us":              "en-US",
+		"de":                 "en-US",
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAcceptLanguage, accept)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err, "app.Test(req)")
+		utils.AssertEqual(t, lang, resp.Header.Get(fiber.HeaderContentLanguage), accept)
+		body, err := ioutil.ReadAll(resp.Body)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, lang, string(body), accept)
+	}
+}
+
+// go test -run Test_Negotiate_normalizeTag
+func Test_Negotiate_normalizeTag(t *testing.T) {
+	t.Parallel()
+	for tag, normalized := range map[string]string{
+		"EN-us":           "en-US",
+		"zh-hant-tw":      "zh-Hant-TW",
+		"ZH-HANT-TW":      "zh-Hant-TW",
+		"es-419":          "es-419",
+		"de-CH-X-Phonebk": "de-CH-x-phonebk",
+		"i-Klingon":       "i-Klingon",
+		"en-GB-OED":       "en-GB-OED",
+		"x-Custom":        "x-Custom",
+	} {
+		utils.AssertEqual(t, normalized, normalizeTag(tag), tag)
+	}
+}
+
+// go test -run Test_Negotiate_ApplyContentType
+func Test_Negotiate_ApplyContentType(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	app.Use(Produces("text/html; charset=utf-8", "xml"))
+	app.Get("/", func(c *fiber.Ctx) error {
+		ApplyContentType(c, c.Locals(ContextKey).(string))
+		return nil
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMETextHTML)
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "text/html; charset=utf-8", resp.Header.Get(fiber.HeaderContentType))
+
+	req = httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationXML)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, fiber.MIMEApplicationXML, resp.Header.Get(fiber.HeaderContentType))
+}
+
+// go test -run Test_Negotiate_ApplyContentType_Charset
+func Test_Negotiate_ApplyContentType_Charset(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	for chosen, contentType := range map[string]string{
+		"text/html":                  "text/html; charset=utf-8",
+		"html":                       "text/html; charset=utf-8",
+		"text/plain; charset=latin1": "text/plain; charset=latin1",
+		"text/plain; Charset=latin1": "text/plain; Charset=latin1",
+		"application/octet-stream":   "application/octet-stream",
+		"png":                        "image/png",
+	} {
+		ApplyContentType(c, chosen, "utf-8")
+		utils.AssertEqual(t, contentType, string(c.Response().Header.ContentType()), chosen)
+	}
+
+	// without a charset text types are untouched
+	ApplyContentType(c, "text/html")
+	utils.AssertEqual(t, "text/html", string(c.Response().Header.ContentType()))
+}
+
+// go test -run Test_Negotiate_Unmatched
+func Test_Negotiate_Unmatched(t *testing.T) {
+	t.Parallel()
+	utils.AssertEqual(t, []string{"image/png"}, Unmatched("image/png, application/json", "application/json"))
+	utils.AssertEqual(t, []string{"image/png"}, Unmatched("image/png;q=0.9, application/json, */*;q=0.1", "json"))
+	utils.AssertEqual(t, []string{"image/png", "application/json"}, Unmatched("image/png, application/json, text/*", "text/html"))
+	utils.AssertEqual(t, []string(nil), Unmatched("image/png, image/webp", "image/*"))
+	utils.AssertEqual(t, []string(nil), Unmatched("", "json"))
+}
+
+// go test -run Test_Negotiate_Resolve
+func Test_Negotiate_Resolve(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []Policy{Policy406, PolicyDefault("text/plain")} {
+		// matching requests
+		offer, err := Resolve("text/html, application/json;q=0.9", policy, "application/json", "text/html")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "text/html", offer)
+		offer, err = Resolve("", policy, "json", "xml")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, "json", offer)
+	}
+
+	// non-matching requests
+	offer, err := Resolve("image/png", Policy406, "json", "xml")
+	utils.AssertEqual(t, fiber.ErrNotAcceptable, err)
+	utils.AssertEqual(t, "", offer)
+	offer, err = Resolve("application/json;q=0, */*", Policy406, "json")
+	utils.AssertEqual(t, fiber.ErrNotAcceptable, err)
+	utils.AssertEqual(t, "", offer)
+	offer, err = Resolve("image/png", PolicyDefault("text/plain"), "json", "xml")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "text/plain", offer)
+}
+
+// go test -run Test_Negotiate_DefaultOffer
+func Test_Negotiate_DefaultOffer(t *testing.T) {
+	t.Parallel()
+	// the default is used without an Accept header
+	for _, accept := range []string{"", " "} {
+		offer, defaulted := DefaultOffer(accept, "json", "xml")
+		utils.AssertEqual(t, "json", offer)
+		utils.AssertEqual(t, true, defaulted)
+	}
+	offer, defaulted := DefaultOffer("")
+	utils.AssertEqual(t, "", offer)
+	utils.AssertEqual(t, false, defaulted)
+
+	// a real Accept header is negotiated
+	offer, defaulted = DefaultOffer("application/xml", "json", "xml")
+	utils.AssertEqual(t, "xml", offer)
+	utils.AssertEqual(t, false, defaulted)
+	offer, defaulted = DefaultOffer("*/*", "json", "xml")
+	utils.AssertEqual(t, "json", offer)
+	utils.AssertEqual(t, false, defaulted)
+	offer, defaulted = DefaultOffer("image/png", "json", "xml")
+	utils.AssertEqual(t, "", offer)
+	utils.AssertEqual(t, false, defaulted)
+}
@@ -21,43 +21,208 @@ type Config struct {
 	// Optional. Default value "session_id".
 	CookieName string
 
+	// Cookie groups the attributes of the session cookie. Its non-zero
+	// values take precedence over the deprecated Cookie* fields.
+	// Optional. Default value CookieConfig{}
+	Cookie CookieConfig
+
 	// Domain of the CSRF cookie.
+	// Deprecated: Use Cookie.Domain instead.
 	// Optional. Default value "".
 	CookieDomain string
 
 	// Path of the CSRF cookie.
+	// Deprecated: Use Cookie.Path instead.
 	// Optional. Default value "".
 	CookiePath string
 
 	// Indicates if CSRF cookie is secure.
+	// Deprecated: Use Cookie.Secure instead.
 	// Optional. Default value false.
 	CookieSecure bool
 
 	// Indicates if CSRF cookie is HTTP only.
+	// Deprecated: Use Cookie.HTTPOnly instead.
 	// Optional. Default value false.
 	CookieHTTPOnly bool
 
 	// Value of SameSite cookie.
+	// Deprecated: Use Cookie.SameSite instead.
 	// Optional. Default value "Lax".
 	CookieSameSite string
 
+	// Indicates if session cookie is partitioned (CHIPS).
+	// Enabling it also sets CookieSecure.
+	// Optional. Default value false.
+	CookiePartitioned bool
+
+	// NoExpiry emits the session cookie without Max-Age and Expires, so it
+	// only lives as long as the browser session. The Storage still expires
+	// the session data after Expiration.
+	// Deprecated: Use Cookie.SessionOnly instead.
+	// Optional. Default value false.
+	NoExpiry bool
+
+	// IDHeader names a response header that carries the session id on
+	// every Save in addition to the cookie, e.g. for scripts. The id is
+	// still only read from the cookie.
+	// Optional. Default value "" (disabled)
+	IDHeader string
+
 	// KeyGenerator generates the session key.
 	// Optional. Default value utils.UUIDv4
 	KeyGenerator func() string
+
+	// StorageKeyFunc transforms the session id before it is used as a key
+	// for the Storage. The cookie still carries the raw session id.
+	// Optional. Default value returns the id unchanged
+	StorageKeyFunc func(id string) string
+
+	// OnModify is called after a session value is set or deleted.
+	// The value is nil for deleted keys. Replace reports all its keys,
+	// WithLock reports the removed keys and all remaining keys as set.
+	// Optional. Default value nil
+	OnModify func(id, key string, value interface{})
+
+	// AuditFunc is called on every access to a session value, op is
+	// "read", "write" or "delete". Values, BindStruct and Replace report
+	// every key they access, WithLock reports a "write" of the key "*".
+	// Optional. Default value nil
+	AuditFunc func(id, op, key string)
+
+	// SkipSave defines a function to skip persisting the session when returned true.
+	// The session is still loaded and usable, but Save neither writes to the
+	// Storage nor sets the session cookie.
+	// Optional. Default value nil
+	SkipSave func(c *fiber.Ctx) bool
+
+	// RotateInterval regenerates the session id on the next Save once the
+	// interval has elapsed since the last rotation. The data moves to the
+	// new id, requests that are skipped or read-only keep the old one.
+	// Optional. Default value 0
+	RotateInterval time.Duration
+
+	// ExpirationHeader names a request header whose duration value, e.g.
+	// "30s", overrides Expiration when the session is saved. Values beyond
+	// Expiration are ignored, so clients can only shorten their sessions.
+	// Meant for testing environments.
+	// Optional. Default value "" (disabled)
+	ExpirationHeader string
+
+	// MaxTTL caps the expiration passed to the Storage on every write,
+	// as a safety net for orphaned sessions.
+	// Optional. Default value 0 (no cap)
+	MaxTTL time.Duration
+
+	// BindClientCert binds sessions to the verified TLS client certificate
+	// they are saved with. Loading a bound session with another or without
+	// a certificate starts a fresh session, sessions saved without a
+	// certificate stay unbound.
+	// Optional. Default value false
+	BindClientCert bool
+
+	// ManualCookie stops the session cookie from being set automatically,
+	// handlers set it with EmitCookie instead. A due RotateInterval is only
+	// applied by EmitCookie, Save keeps the id the client knows.
+	// Optional. Default value false
+	ManualCookie bool
+
+	// LazyCreate defers creating a new session until a value is written.
+	// Until then the session has no id and Save neither writes to the
+	// Storage nor sets the session cookie.
+	// Optional. Default value false
+	LazyCreate bool
+
+	// ReadOnlyPanic panics with ErrReadOnly on writes to a session of a
+	// request marked with ReadOnly. Otherwise the writes are dropped and
+	// Save returns ErrReadOnly.
+	// Optional. Default value false
+	ReadOnlyPanic bool
+
+	// DataVersion is stored alongside the session data. Sessions loaded
+	// with another version are passed to Migrate.
+	// Optional. Default value 0
+	DataVersion int
+
+	// Migrate transforms the data of a session stored with another
+	// DataVersion on load, the result is saved with the next Save. Without
+	// Migrate such sessions are treated as fresh sessions.
+	// Optional. Default value nil
+	Migrate func(old map[string]interface{}, fromVersion int) map[string]interface{}
+
+	// OnGC is called after every garbage collection sweep of the default
+	// memory Storage with the number of removed and scanned entries.
+	// It is not used with a custom Storage.
+	// Optional. Default value nil
+	OnGC func(removed, scanned int, d time.Duration)
+}
+
+// CookieConfig defines the attributes of the session cookie.
+type CookieConfig struct {
+	// Domain of the session cookie.
+	// Optional. Default value "".
+	Domain string
+
+	// Path of the session cookie.
+	// Optional. Default value "".
+	Path string
+
+	// Indicates if the session cookie is secure.
+	// Optional. Default value false.
+	Secure bool
+
+	// Indicates if the session cookie is HTTP only.
+	// Optional. Default value false.
+	HTTPOnly bool
+
+	// Value of SameSite cookie.
+	// Optional. Default value "Lax".
+	SameSite string
+
+	// SessionOnly emits the session cookie without Max-Age and Expires,
+	// see NoExpiry.
+	// Optional. Default value false.
+	SessionOnly bool
+
+	// MaxAge of the session cookie, independent of the session Expiration.
+	// Optional. Default value Expiration
+	MaxAge time.Duration
+
+	// ExpiryBuffer is added to the Max-Age and Expires of the session
+	// cookie to tolerate clock skew, the Storage expiry is not affected.
+	// Optional. Default value 0
+	ExpiryBuffer time.Duration
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Expiration:   24 * time.Hour,
-	CookieName:   "session_id",
-	KeyGenerator: utils.UUIDv4,
+	Expiration:     24 * time.Hour,
+	CookieName:     "session_id",
+	KeyGenerator:   utils.UUIDv4,
+	StorageKeyFunc: func(id string) string { return id },
+}
+
+// Harden returns a copy of the config with all security sensitive cookie
+// options enabled. Fields can still be overridden afterwards.
+func (cfg Config) Harden() Config {
+	cfg.CookieSecure = true
+	cfg.CookieHTTPOnly = true
+	if cfg.CookieSameSite == "" {
+		cfg.CookieSameSite = "Lax"
+	}
+	if int(cfg.Expiration.Seconds()) <= 0 {
+		cfg.Expiration = ConfigDefault.Expiration
+	}
+	return cfg
 }
 
 // Helper function to set default values
 func configDefault(config ...Config) Config {
 	// Return default config if nothing provided
 	if len(config) < 1 {
-		return ConfigDefault
+		cfg := ConfigDefault
+		cfg.mergeCookie()
+		return cfg
 	}
 
 	// Override default config
@@ -73,5 +238,39 @@ func configDefault(config ...Config) Config {
 	if cfg.KeyGenerator == nil {
 		cfg.KeyGenerator = ConfigDefault.KeyGenerator
 	}
+	if cfg.StorageKeyFunc == nil {
+		cfg.StorageKeyFunc = ConfigDefault.StorageKeyFunc
+	}
+	if cfg.CookiePartitioned {
+		cfg.CookieSecure = true
+	}
+	cfg.mergeCookie()
 	return cfg
 }
+
+// mergeCookie applies the grouped cookie config to the flat Cookie* fields
+// and fills the unset grouped values from them
+func (cfg *Config) mergeCookie() {
+	if cfg.Cookie.Domain != "" {
+		cfg.CookieDomain = cfg.Cookie.Domain
+	}
+	if cfg.Cookie.Path != "" {
+		cfg.CookiePath = cfg.Cookie.Path
+	}
+	if cfg.Cookie.SameSite != "" {
+		cfg.CookieSameSite = cfg.Cookie.SameSite
+	}
+	cfg.CookieSecure = cfg.CookieSecure || cfg.Cookie.Secure
+	cfg.CookieHTTPOnly = cfg.CookieHTTPOnly || cfg.Cookie.HTTPOnly
+	cfg.NoExpiry = cfg.NoExpiry || cfg.Cookie.SessionOnly
+	if int(cfg.Cookie.MaxAge.Seconds()) <= 0 {
+		cfg.Cookie.MaxAge = cfg.Expiration
+	}
+
+	cfg.Cookie.Domain = cfg.CookieDomain
+	cfg.Cookie.Path = cfg.CookiePath
+	cfg.Cookie.SameSite = cfg.CookieSameSite
+	cfg.Cookie.Secure = cfg.CookieSecure
+	cfg.Cookie.HTTPOnly = cfg.CookieHTTPOnly
+	cfg.Cookie.SessionOnly = cfg.NoExpiry
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"reflect"
 	"sync"
 )
 
@@ -11,6 +12,7 @@ import (
 type data struct {
 	sync.RWMutex
 	Data map[string]interface{}
+	Meta map[string]string
 }
 
 var dataPool = sync.Pool{
@@ -35,6 +37,7 @@ func (d *data) Reset() {
 	for key := range d.Data {
 		delete(d.Data, key)
 	}
+	d.Meta = nil
 	d.Unlock()
 }
 
@@ -51,12 +54,117 @@ func (d *data) Set(key string, value interface{}) {
 	d.Unlock()
 }
 
-func (d *data) Delete(key string) {
+func (d *data) SetIfAbsent(key string, value interface{}) (interface{}, bool) {
 	d.Lock()
+	defer d.Unlock()
+	if v, ok := d.Data[key]; ok {
+		return v, false
+	}
+	d.Data[key] = value
+	return value, true
+}
+
+func (d *data) Append(key string, value interface{}, maxLen int) []interface{} {
+	d.Lock()
+	defer d.Unlock()
+	list := toList(d.Data[key])
+	// copy the list, it may have been handed out before
+	start := 0
+	if maxLen > 0 && len(list)+1 > maxLen {
+		start = len(list) + 1 - maxLen
+	}
+	if start > len(list) {
+		start = len(list)
+	}
+	appended := make([]interface{}, 0, len(list)-start+1)
+	appended = append(appended, list[start:]...)
+	appended = append(appended, value)
+	d.Data[key] = appended
+	return appended
+}
+
+// toList returns the elements of slices and arrays of any kind, other values
+// have none
+func toList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+func (d *data) Update(fn func(values map[string]interface{})) {
+	d.Lock()
+	defer d.Unlock()
+	fn(d.Data)
+}
+
+func (d *data) Replace(m map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		data[key] = value
+	}
+	d.Lock()
+	old := d.Data
+	d.Data = data
+	d.Unlock()
+	return old
+}
+
+func (d *data) DeleteMeta(key string) {
+	d.Lock()
+	delete(d.Meta, key)
+	d.Unlock()
+}
+
+func (d *data) Delete(key string) bool {
+	d.Lock()
+	_, ok := d.Data[key]
 	delete(d.Data, key)
 	d.Unlock()
+	return ok
+}
+
+func (d *data) Copy() map[string]interface{} {
+	d.RLock()
+	m := make(map[string]interface{}, len(d.Data))
+	for key, value := range d.Data {
+		m[key] = value
+	}
+	d.RUnlock()
+	return m
 }
 
 func (d *data) Len() int {
 	return len(d.Data)
 }
+
+func (d *data) Empty() bool {
+	d.RLock()
+	empty := len(d.Data) == 0 && len(d.Meta) == 0
+	d.RUnlock()
+	return empty
+}
+
+func (d *data) GetMeta(key string) string {
+	d.RLock()
+	v := d.Meta[key]
+	d.RUnlock()
+	return v
+}
+
+func (d *data) SetMeta(key, value string) {
+	d.Lock()
+	if d.Meta == nil {
+		d.Meta = make(map[string]string)
+	}
+	d.Meta[key] = value
+	d.Unlock()
+}
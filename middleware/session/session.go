@@ -3,6 +3,10 @@ package session
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
 	"sync"
 	"time"
 
@@ -11,9 +15,26 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+// ErrReadOnly is returned for writes to a session of a request marked with ReadOnly
+var ErrReadOnly = errors.New("session: write to a read-only session")
+
+// readOnlyKey marks read-only requests in the locals
+const readOnlyKey = "session_read_only"
+
 type Session struct {
 	id         string        // session id
 	fresh      bool          // if new session
+	violated   bool          // if a write happened in read-only mode
+	reissue    bool          // if the cookie lifetime changed since the load
+	rotateDue  bool          // if the RotateInterval elapsed since the last rotation
+	oldKey     string        // Storage key of the id before a rotation, removed on Save
+	expires    time.Time     // when the stored data expires
+	rotated    time.Time     // when the id was last rotated
+	created    time.Time     // when the session was first saved
+	sudoUntil  time.Time     // when the elevated privileges end
+	remember   time.Duration // lifetime set by Remember
+	clientCert string        // fingerprint of the bound client certificate
+	cookie     *fiber.Cookie // cookie handed out by Cookie, set by Save
 	ctx        *fiber.Ctx    // fiber context
 	config     *Store        // store configuration
 	data       *data         // key value data
@@ -40,6 +61,17 @@ func acquireSession() *Session {
 
 func releaseSession(s *Session) {
 	s.id = ""
+	s.violated = false
+	s.reissue = false
+	s.rotateDue = false
+	s.oldKey = ""
+	s.expires = time.Time{}
+	s.rotated = time.Time{}
+	s.created = time.Time{}
+	s.sudoUntil = time.Time{}
+	s.remember = 0
+	s.clientCert = ""
+	s.cookie = nil
 	s.ctx = nil
 	s.config = nil
 	if s.data != nil {
@@ -67,25 +99,325 @@ func (s *Session) Get(key string) interface{} {
 	if s.data == nil {
 		return nil
 	}
+	s.audit("read", key)
 	return s.data.Get(key)
 }
 
+// Values returns a shallow copy of all values, e.g. for templates
+func (s *Session) Values() map[string]interface{} {
+	// Better safe than sorry
+	if s.data == nil {
+		return nil
+	}
+	values := s.data.Copy()
+	for key := range values {
+		s.audit("read", key)
+	}
+	return values
+}
+
 // Set will update or create a new key value
 func (s *Session) Set(key string, val interface{}) {
 	// Better safe than sorry
-	if s.data == nil {
+	if s.data == nil || s.readOnly() {
 		return
 	}
+	s.create()
+	s.audit("write", key)
 	s.data.Set(key, val)
+	s.modified(key, val)
+}
+
+// SetIfAbsent will set the value only if the key does not exist yet.
+// It returns the value stored for the key and whether it was set.
+func (s *Session) SetIfAbsent(key string, val interface{}) (actual interface{}, set bool) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return nil, false
+	}
+	s.create()
+	s.audit("write", key)
+	if actual, set = s.data.SetIfAbsent(key, val); set {
+		s.modified(key, val)
+	}
+	return actual, set
+}
+
+// Append adds the value to the list stored for the key, dropping the
+// oldest values beyond maxLen if maxLen is positive. Other slices, e.g. a
+// []string, are converted to a []interface{} keeping their elements, a
+// missing key or a value that isn't a slice starts a new list.
+func (s *Session) Append(key string, value interface{}, maxLen int) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return
+	}
+	s.create()
+	s.audit("write", key)
+	s.modified(key, s.data.Append(key, value, maxLen))
+}
+
+// WithLock calls fn with the values of the session while holding its write
+// lock, so several reads and writes happen as one critical section for
+// everyone sharing this session object. fn must only read and write the
+// passed map and must not call methods of the session, which wait for the
+// lock fn holds and never return.
+func (s *Session) WithLock(fn func(values map[string]interface{})) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return
+	}
+	s.create()
+	s.audit("write", "*")
+	if s.config == nil || s.config.OnModify == nil {
+		s.data.Update(fn)
+		return
+	}
+
+	// Collect the changes for OnModify, it's called after the lock is released
+	var removed []string
+	var values map[string]interface{}
+	s.data.Update(func(data map[string]interface{}) {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		fn(data)
+		for _, key := range keys {
+			if _, ok := data[key]; !ok {
+				removed = append(removed, key)
+			}
+		}
+		values = make(map[string]interface{}, len(data))
+		for key, val := range data {
+			values[key] = val
+		}
+	})
+	for _, key := range removed {
+		s.modified(key, nil)
+	}
+	for key, val := range values {
+		s.modified(key, val)
+	}
+}
+
+// Replace swaps all values with a copy of m at once
+func (s *Session) Replace(m map[string]interface{}) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return
+	}
+	s.create()
+	old := s.data.Replace(m)
+	for key := range old {
+		if _, ok := m[key]; !ok {
+			s.audit("delete", key)
+			s.modified(key, nil)
+		}
+	}
+	for key, val := range m {
+		s.audit("write", key)
+		s.modified(key, val)
+	}
+}
+
+// Delete will delete the value and reports whether the key existed
+func (s *Session) Delete(key string) bool {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return false
+	}
+	s.audit("delete", key)
+	existed := s.data.Delete(key)
+	s.modified(key, nil)
+	return existed
+}
+
+// ReadOnly marks the request so that writes to its session are rejected
+// and the session is not saved
+func ReadOnly(c *fiber.Ctx) {
+	c.Locals(readOnlyKey, true)
+}
+
+// readOnly reports whether the request is marked with ReadOnly and
+// records the rejected write
+func (s *Session) readOnly() bool {
+	if s.ctx == nil || s.ctx.Locals(readOnlyKey) != true {
+		return false
+	}
+	if s.config != nil && s.config.ReadOnlyPanic {
+		panic(ErrReadOnly)
+	}
+	s.violated = true
+	return true
 }
 
-// Delete will delete the value
-func (s *Session) Delete(key string) {
+// BindStruct populates the fields of the struct out points to with the
+// session values named by their `session` tag. Fields without a value
+// are left unchanged.
+func (s *Session) BindStruct(out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("session: BindStruct requires a non-nil pointer to a struct, got %T", out)
+	}
 	// Better safe than sorry
 	if s.data == nil {
+		return nil
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key := sessionTag(rt.Field(i))
+		if key == "" {
+			continue
+		}
+		s.audit("read", key)
+		val := s.data.Get(key)
+		if val == nil {
+			continue
+		}
+		v := reflect.ValueOf(val)
+		if !v.Type().AssignableTo(rt.Field(i).Type) {
+			return fmt.Errorf("session: cannot bind %q of type %s to field %s of type %s", key, v.Type(), rt.Field(i).Name, rt.Field(i).Type)
+		}
+		rv.Field(i).Set(v)
+	}
+	return nil
+}
+
+// StoreStruct sets a session value for every field of the struct in
+// with a `session` tag, in may also be a pointer to a struct.
+func (s *Session) StoreStruct(in interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(in))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("session: StoreStruct requires a struct, got %T", in)
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if key := sessionTag(rt.Field(i)); key != "" {
+			s.Set(key, rv.Field(i).Interface())
+		}
+	}
+	return nil
+}
+
+// sessionTag returns the session key of an exported struct field
+func sessionTag(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	key := field.Tag.Get("session")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// create generates the key of a lazily created session
+func (s *Session) create() {
+	if s.id == "" && s.config != nil {
+		s.id = s.config.KeyGenerator()
+	}
+}
+
+// GetMeta returns the server side metadata value of the key. Metadata
+// is stored with the session but kept apart from its values.
+func (s *Session) GetMeta(key string) string {
+	// Better safe than sorry
+	if s.data == nil {
+		return ""
+	}
+	return s.data.GetMeta(key)
+}
+
+// SetMeta will update or create a metadata value
+func (s *Session) SetMeta(key, value string) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return
+	}
+	s.create()
+	s.data.SetMeta(key, value)
+}
+
+// audit reports an access to the AuditFunc
+func (s *Session) audit(op, key string) {
+	if s.config != nil && s.config.AuditFunc != nil {
+		s.config.AuditFunc(s.id, op, key)
+	}
+}
+
+// Sudo grants elevated privileges for the duration d, e.g. after the
+// user re-entered the password. Regenerate revokes them.
+func (s *Session) Sudo(d time.Duration) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
+		return
+	}
+	s.create()
+	s.sudoUntil = time.Now().Add(d)
+}
+
+// IsSudo reports whether the session has elevated privileges
+func (s *Session) IsSudo() bool {
+	return time.Now().Before(s.sudoUntil)
+}
+
+// Remember switches the session to the longer lifetime d, e.g. for a
+// "remember me" login. Both the Storage expiration and the cookie use d
+// from the next Save on, also if the cookie would otherwise only live
+// for the browser session. The choice is stored with the session.
+func (s *Session) Remember(d time.Duration) {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() || d <= 0 {
+		return
+	}
+	s.create()
+	s.remember = d
+	s.reissue = true
+}
+
+// Forget reverts a Remember, the session uses the configured
+// Expiration again from the next Save on
+func (s *Session) Forget() {
+	// Better safe than sorry
+	if s.data == nil || s.readOnly() {
 		return
 	}
-	s.data.Delete(key)
+	s.remember = 0
+	s.reissue = true
+}
+
+// remembered returns the duration passed to Remember, or zero
+func (s *Session) remembered() time.Duration {
+	return s.remember
+}
+
+// modified notifies the OnModify callback, it must be called
+// after the data lock has been released
+func (s *Session) modified(key string, val interface{}) {
+	if s.config != nil && s.config.OnModify != nil {
+		s.config.OnModify(s.id, key, val)
+	}
+}
+
+// SizeBytes returns the exact size of the encoded session data, this is
+// the amount of bytes Save will pass to the Storage if nothing changes
+// before it is called
+func (s *Session) SizeBytes() (int, error) {
+	// Better safe than sorry
+	if s.data == nil || s.empty() {
+		return 0, nil
+	}
+
+	// Encoded the same way as Save, with its expiration
+	var buf bytes.Buffer
+	if err := s.encode(&buf, time.Now().Add(s.capTTL(s.expiration()))); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
 }
 
 // Destroy will delete the session from Storage and expire session cookie
@@ -97,27 +429,131 @@ func (s *Session) Destroy() error {
 
 	// Reset local data
 	s.data.Reset()
+	s.sudoUntil = time.Time{}
+	s.remember = 0
+	s.clientCert = ""
 
 	// Use external Storage if exist
-	if err := s.config.Storage.Delete(s.id); err != nil {
+	key := s.config.StorageKeyFunc(s.id)
+	if err := s.config.Storage.Delete(key); err != nil {
 		return err
 	}
+	s.config.untrack(key)
 
 	// Expire cookie
 	s.delCookie()
 	return nil
 }
 
+// ExpireNow marks the session as expired in the Storage without deleting it,
+// the next load with the same id starts a fresh session and the Storage
+// removes the data on its own. Calling Save afterwards revives the session.
+func (s *Session) ExpireNow() error {
+	// Better safe than sorry
+	if s.data == nil {
+		return nil
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	// an expiry in the past makes the entry unreadable right away, the
+	// Storage gets a short positive TTL as some treat others as no expiry
+	if err := s.write(time.Now().Add(-time.Second), time.Second); err != nil {
+		return err
+	}
+	s.config.untrack(s.config.StorageKeyFunc(s.id))
+	return nil
+}
+
+// empty reports whether there is nothing to store for the session
+func (s *Session) empty() bool {
+	return s.data.Empty() && s.sudoUntil.IsZero() && s.remember == 0 && s.clientCert == ""
+}
+
+// expired reports whether the loaded data is past its stored expiry
+func (s *Session) expired() bool {
+	return !s.expires.IsZero() && !time.Now().Before(s.expires)
+}
+
+// reset drops the values, metadata and timestamps of the session
+func (s *Session) reset() {
+	s.data.Reset()
+	s.expires = time.Time{}
+	s.rotated = time.Time{}
+	s.created = time.Time{}
+	s.sudoUntil = time.Time{}
+	s.remember = 0
+	s.clientCert = ""
+}
+
+// ExpiresIn returns the time left until the stored session expires, it is
+// not positive for an expired session. Sessions that haven't been saved
+// yet report the full Expiration.
+func (s *Session) ExpiresIn() time.Duration {
+	if s.expires.IsZero() {
+		return s.expiration()
+	}
+	return time.Until(s.expires)
+}
+
+// Age returns the time since the session was created, it is zero for a
+// session that hasn't been saved yet
+func (s *Session) Age() time.Duration {
+	if s.created.IsZero() {
+		return 0
+	}
+	return time.Since(s.created)
+}
+
+// Refresh reloads the session data from the Storage, e.g. to observe
+// changes saved by another request. Unsaved local changes are discarded.
+func (s *Session) Refresh() error {
+	// Better safe than sorry
+	if s.data == nil {
+		return nil
+	}
+
+	raw, err := s.config.Storage.Get(s.config.StorageKeyFunc(s.id))
+	if err != nil {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	s.data.Reset()
+	if raw == nil {
+		return nil
+	}
+	version, err := s.decode(raw)
+	if err != nil {
+		return err
+	}
+	if s.expired() {
+		s.reset()
+		return nil
+	}
+	s.config.migrate(s, version)
+	return nil
+}
+
 // Regenerate generates a new session id and delete the old one from Storage
 func (s *Session) Regenerate() error {
 
 	// Delete old id from storage
-	if err := s.config.Storage.Delete(s.id); err != nil {
+	key := s.config.StorageKeyFunc(s.id)
+	if err := s.config.Storage.Delete(key); err != nil {
 		return err
 	}
+	s.config.untrack(key)
 
-	// Create new ID
+	// Create new ID, which also counts as rotation
 	s.id = s.config.KeyGenerator()
+	s.rotateDue = false
+	s.rotated = time.Now()
+
+	// Elevated privileges don't carry over to the new ID
+	s.sudoUntil = time.Time{}
 
 	return nil
 }
@@ -130,29 +566,57 @@ func (s *Session) Save() error {
 		return nil
 	}
 
-	// Create cookie with the session ID if fresh
-	if s.fresh {
+	// Don't persist anything if the request is skipped
+	if s.config.SkipSave != nil && s.config.SkipSave(s.ctx) {
+		return nil
+	}
+
+	// Read-only requests are never persisted
+	if s.ctx != nil && s.ctx.Locals(readOnlyKey) == true {
+		if s.violated {
+			return ErrReadOnly
+		}
+		return nil
+	}
+
+	// Lazy sessions are only created once a value is written
+	if s.config.LazyCreate && s.fresh && s.empty() {
+		return nil
+	}
+
+	// Move the data to a new id once the RotateInterval has elapsed, with a
+	// ManualCookie only EmitCookie does, as no cookie would carry the new id
+	if !s.config.ManualCookie {
+		s.rotate()
+	}
+
+	// Create cookie with the session ID if fresh, rotated or its lifetime changed
+	if (s.fresh || s.reissue || s.oldKey != "") && !s.config.ManualCookie {
 		s.setCookie()
 	}
 
+	// Expose the session ID to scripts as well
+	if s.config.IDHeader != "" {
+		s.ctx.Set(s.config.IDHeader, s.id)
+	}
+
 	// Don't save to Storage if no data is available
-	if s.data.Len() <= 0 {
+	if s.empty() {
 		return nil
 	}
 
-	// Convert data to bytes
 	mux.Lock()
 	defer mux.Unlock()
-	encCache := gob.NewEncoder(s.byteBuffer)
-	err := encCache.Encode(&s.data.Data)
-	if err != nil {
+	if err := s.persist(s.expiration()); err != nil {
 		return err
 	}
-
-	// pass raw bytes with session id to provider
-	if err := s.config.Storage.Set(s.id, s.byteBuffer.Bytes(), s.config.Expiration); err != nil {
-		return err
+	if s.oldKey != "" {
+		if err := s.config.Storage.Delete(s.oldKey); err != nil {
+			return err
+		}
+		s.config.untrack(s.oldKey)
 	}
+	s.config.track(s.config.StorageKeyFunc(s.id), s.expires)
 
 	// Release session
 	// TODO: It's not safe to use the Session after called Save()
@@ -161,19 +625,242 @@ func (s *Session) Save() error {
 	return nil
 }
 
-func (s *Session) setCookie() {
-	fcookie := fasthttp.AcquireCookie()
-	fcookie.SetKey(s.config.CookieName)
-	fcookie.SetValue(s.id)
-	fcookie.SetPath(s.config.CookiePath)
-	fcookie.SetDomain(s.config.CookieDomain)
-	fcookie.SetMaxAge(int(s.config.Expiration.Seconds()))
-	fcookie.SetExpire(time.Now().Add(s.config.Expiration))
-	fcookie.SetSecure(s.config.CookieSecure)
-	fcookie.SetHTTPOnly(s.config.CookieHTTPOnly)
+// rotate switches to a new id if a rotation is due, Save moves the data
+// from the old id
+func (s *Session) rotate() {
+	if !s.rotateDue || s.empty() {
+		return
+	}
+	s.rotateDue = false
+	s.oldKey = s.config.StorageKeyFunc(s.id)
+	s.id = s.config.KeyGenerator()
+	s.rotated = time.Now()
+}
+
+// skipped reports whether Save won't persist the session of the request
+func (s *Session) skipped() bool {
+	if s.config.SkipSave != nil && s.config.SkipSave(s.ctx) {
+		return true
+	}
+	return s.ctx != nil && s.ctx.Locals(readOnlyKey) == true
+}
+
+// expiration returns the Expiration, overridden by Remember or the
+// ExpirationHeader of the request if enabled
+func (s *Session) expiration() time.Duration {
+	if d := s.remembered(); d > 0 {
+		return d
+	}
+	if s.config.ExpirationHeader == "" || s.ctx == nil {
+		return s.config.Expiration
+	}
+	// Clients may only shorten their sessions
+	if exp, err := time.ParseDuration(s.ctx.Get(s.config.ExpirationHeader)); err == nil && exp > 0 && exp < s.config.Expiration {
+		return exp
+	}
+	return s.config.Expiration
+}
+
+// persist encodes the data and passes it to the Storage, the caller
+// must hold the mux lock
+func (s *Session) persist(ttl time.Duration) error {
+	ttl = s.capTTL(ttl)
+	return s.write(time.Now().Add(ttl), ttl)
+}
+
+// capTTL limits the ttl to the MaxTTL
+func (s *Session) capTTL(ttl time.Duration) time.Duration {
+	if s.config.MaxTTL > 0 && ttl > s.config.MaxTTL {
+		return s.config.MaxTTL
+	}
+	return ttl
+}
+
+// boundCert returns the client certificate fingerprint the session is
+// saved with, sessions are bound to the certificate of their first save
+func (s *Session) boundCert() string {
+	if s.clientCert == "" && s.config != nil && s.config.BindClientCert && s.ctx != nil {
+		return s.config.fingerprint(s.ctx)
+	}
+	return s.clientCert
+}
+
+// write encodes the data with the expiry and passes it to the Storage
+// with the ttl, the caller must hold the mux lock
+func (s *Session) write(expires time.Time, ttl time.Duration) error {
+	if s.created.IsZero() {
+		s.created = time.Now()
+	}
+	// Bind the session to the client certificate it is saved with
+	s.clientCert = s.boundCert()
+
+	// Convert data to bytes
+	s.byteBuffer.Reset()
+	if err := s.encode(s.byteBuffer, expires); err != nil {
+		return err
+	}
+
+	// copy the encoded bytes, the buffer is reused once the session is released
+	encodedBytes := make([]byte, s.byteBuffer.Len())
+	copy(encodedBytes, s.byteBuffer.Bytes())
+
+	// pass raw bytes with session id to provider
+	if err := s.config.Storage.Set(s.config.StorageKeyFunc(s.id), encodedBytes, ttl); err != nil {
+		return err
+	}
+	s.expires = expires
+	return nil
+}
+
+// encode writes the gob encoded record of the session to w, with the
+// expiry, the creation, the last rotation and DataVersion
+func (s *Session) encode(w io.Writer, expires time.Time) error {
+	s.data.RLock()
+	defer s.data.RUnlock()
+	rec := record{
+		Data:       s.data.Data,
+		Meta:       s.data.Meta,
+		Expires:    expires.Unix(),
+		Created:    s.created.Unix(),
+		Remember:   int64(s.remember),
+		ClientCert: s.boundCert(),
+	}
+	if s.created.IsZero() {
+		rec.Created = time.Now().Unix()
+	}
+	if s.config != nil && s.config.RotateInterval > 0 {
+		rec.Rotated = time.Now().Unix()
+		if !s.rotated.IsZero() {
+			rec.Rotated = s.rotated.Unix()
+		}
+	}
+	if !s.sudoUntil.IsZero() {
+		rec.SudoUntil = s.sudoUntil.UnixNano()
+	}
+	if s.config != nil {
+		rec.Version = s.config.DataVersion
+	}
+	return gob.NewEncoder(w).Encode(&rec)
+}
+
+// decode restores the values, metadata and timestamps of raw and returns
+// the DataVersion it was stored with, the caller must hold the mux lock
+func (s *Session) decode(raw []byte) (int, error) {
+	s.byteBuffer.Reset()
+	_, _ = s.byteBuffer.Write(raw)
+	var rec record
+	if err := gob.NewDecoder(s.byteBuffer).Decode(&rec); err != nil {
+		// Sessions saved before the record format only hold the values
+		if gob.NewDecoder(bytes.NewReader(raw)).Decode(&rec.Data) != nil {
+			return 0, err
+		}
+	}
+	if rec.Data == nil {
+		rec.Data = make(map[string]interface{})
+	}
+
+	s.data.Lock()
+	s.data.Data = rec.Data
+	s.data.Meta = rec.Meta
+	s.data.Unlock()
+	s.expires = unixTime(rec.Expires)
+	s.created = unixTime(rec.Created)
+	s.rotated = unixTime(rec.Rotated)
+	s.remember = time.Duration(rec.Remember)
+	s.clientCert = rec.ClientCert
+	s.sudoUntil = time.Time{}
+	if rec.SudoUntil != 0 {
+		s.sudoUntil = time.Unix(0, rec.SudoUntil)
+	}
+	return rec.Version, nil
+}
+
+// unixTime converts unix seconds to a time, zero stays the zero time
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Cookie returns the session cookie that Save or EmitCookie set, e.g. for
+// a fresh session. Changes to it are applied when the cookie is set, only
+// the value always carries the current session id. It is created with the
+// first call, so call it after Remember or Forget. The Partitioned attribute
+// has no field, it is added for a CookiePartitioned config.
+func (s *Session) Cookie() *fiber.Cookie {
+	if s.cookie == nil {
+		s.cookie = s.newCookie()
+	}
+	s.cookie.Value = s.id
+	return s.cookie
+}
+
+// newCookie creates the session cookie from the config
+func (s *Session) newCookie() *fiber.Cookie {
+	cookie := &fiber.Cookie{
+		Name:     s.config.CookieName,
+		Value:    s.id,
+		Path:     s.config.CookiePath,
+		Domain:   s.config.CookieDomain,
+		Secure:   s.config.CookieSecure,
+		HTTPOnly: s.config.CookieHTTPOnly,
+	}
+	// A session cookie is dropped when the browser is closed
+	remembered := s.remembered()
+	if !s.config.NoExpiry || remembered > 0 {
+		maxAge := s.config.Cookie.MaxAge
+		if remembered > 0 {
+			maxAge = remembered
+		}
+		maxAge += s.config.Cookie.ExpiryBuffer
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	}
 
 	// TODO Default value should be set to `strict` in fiber v3.
 	switch utils.ToLower(s.config.CookieSameSite) {
+	case "strict":
+		cookie.SameSite = "Strict"
+	case "none":
+		cookie.SameSite = "None"
+	default:
+		cookie.SameSite = "Lax"
+	}
+	return cookie
+}
+
+// EmitCookie sets the session cookie on the response, it is meant for
+// stores with ManualCookie enabled and must be called before Save
+func EmitCookie(c *fiber.Ctx, sess *Session) {
+	// Better safe than sorry
+	if sess.config == nil {
+		return
+	}
+	sess.ctx = c
+	sess.create()
+	// A due rotation is applied now, so the cookie carries the new id
+	if !sess.skipped() {
+		sess.rotate()
+	}
+	sess.setCookie()
+}
+
+func (s *Session) setCookie() {
+	cookie := s.Cookie()
+	fcookie := fasthttp.AcquireCookie()
+	fcookie.SetKey(cookie.Name)
+	fcookie.SetValue(cookie.Value)
+	fcookie.SetPath(cookie.Path)
+	fcookie.SetDomain(cookie.Domain)
+	if cookie.MaxAge > 0 {
+		fcookie.SetMaxAge(cookie.MaxAge)
+		fcookie.SetExpire(cookie.Expires)
+	}
+	fcookie.SetSecure(cookie.Secure)
+	fcookie.SetHTTPOnly(cookie.HTTPOnly)
+
+	switch utils.ToLower(cookie.SameSite) {
 	case "strict":
 		fcookie.SetSameSite(fasthttp.CookieSameSiteStrictMode)
 	case "none":
@@ -182,7 +869,7 @@ func (s *Session) setCookie() {
 		fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
 	}
 
-	s.ctx.Response().Header.SetCookie(fcookie)
+	s.writeCookie(fcookie)
 	fasthttp.ReleaseCookie(fcookie)
 }
 
@@ -208,6 +895,17 @@ func (s *Session) delCookie() {
 		fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
 	}
 
-	s.ctx.Response().Header.SetCookie(fcookie)
+	s.writeCookie(fcookie)
 	fasthttp.ReleaseCookie(fcookie)
 }
+
+func (s *Session) writeCookie(fcookie *fasthttp.Cookie) {
+	if !s.config.CookiePartitioned {
+		s.ctx.Response().Header.SetCookie(fcookie)
+		return
+	}
+	// fasthttp has no support for the Partitioned attribute, so the
+	// raw cookie replaces the one with the same name
+	s.ctx.Response().Header.DelCookieBytes(fcookie.Key())
+	s.ctx.Response().Header.Set(fiber.HeaderSetCookie, string(fcookie.Cookie())+"; Partitioned")
+}
@@ -1,6 +1,17 @@
 package session
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -256,6 +267,33 @@ func Test_Session_Custom_Config(t *testing.T) {
 	utils.AssertEqual(t, ConfigDefault.Expiration, store.Expiration)
 }
 
+// go test -run Test_Session_Config_Harden
+func Test_Session_Config_Harden(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{CookieName: "sid"}.Harden()
+	utils.AssertEqual(t, "sid", cfg.CookieName)
+	utils.AssertEqual(t, true, cfg.CookieSecure)
+	utils.AssertEqual(t, true, cfg.CookieHTTPOnly)
+	utils.AssertEqual(t, "Lax", cfg.CookieSameSite)
+	utils.AssertEqual(t, ConfigDefault.Expiration, cfg.Expiration)
+
+	// explicit values are kept
+	cfg = Config{CookieSameSite: "Strict", Expiration: time.Hour}.Harden()
+	utils.AssertEqual(t, "Strict", cfg.CookieSameSite)
+	utils.AssertEqual(t, time.Hour, cfg.Expiration)
+
+	// and the cookie carries every flag
+	store := New(Config{}.Harden())
+	app := fiber.New()
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.Contains(cookie, "; HttpOnly; secure; SameSite=Lax"))
+}
+
 // go test -run Test_Session_Cookie
 func Test_Session_Cookie(t *testing.T) {
 	t.Parallel()
@@ -299,6 +337,1932 @@ func Test_Session_Cookie_In_Response(t *testing.T) {
 	utils.AssertEqual(t, "john", sess.Get("name"))
 }
 
+// go test -run Test_Session_StorageKeyFunc
+func Test_Session_StorageKeyFunc(t *testing.T) {
+	t.Parallel()
+
+	hash := func(id string) string {
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:])
+	}
+	storage := memory.New()
+	store := New(Config{
+		Storage:        storage,
+		StorageKeyFunc: hash,
+		KeyGenerator:   func() string { return "raw-id" },
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// get session
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, "raw-id", sess.ID())
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// cookie carries the raw id
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	utils.AssertEqual(t, nil, cookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, "raw-id", string(cookie.Value()))
+
+	// storage uses the hashed key
+	raw, _ := storage.Get("raw-id")
+	utils.AssertEqual(t, true, raw == nil)
+	raw, _ = storage.Get(hash("raw-id"))
+	utils.AssertEqual(t, true, raw != nil)
+
+	// load and destroy through the hashed key
+	ctx.Request().Header.SetCookie(store.CookieName, "raw-id")
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Destroy())
+	raw, _ = storage.Get(hash("raw-id"))
+	utils.AssertEqual(t, true, raw == nil)
+}
+
+// go test -race -run Test_Session_ActiveCount
+func Test_Session_ActiveCount(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	const n = 50
+	ids := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(ctx)
+			sess, err := store.Get(ctx)
+			utils.AssertEqual(t, nil, err)
+			id := sess.ID()
+			sess.Set("name", "john")
+			utils.AssertEqual(t, nil, sess.Save())
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	utils.AssertEqual(t, int64(n), store.ActiveCount())
+
+	// saving an existing session again does not count it twice
+	var destroy []string
+	for id := range ids {
+		destroy = append(destroy, id)
+	}
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	ctx.Request().Header.SetCookie(store.CookieName, destroy[0])
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "doe")
+	utils.AssertEqual(t, nil, sess.Save())
+	app.ReleaseCtx(ctx)
+	utils.AssertEqual(t, int64(n), store.ActiveCount())
+
+	for _, id := range destroy[:n/2] {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(ctx)
+			ctx.Request().Header.SetCookie(store.CookieName, id)
+			sess, err := store.Get(ctx)
+			utils.AssertEqual(t, nil, err)
+			utils.AssertEqual(t, nil, sess.Destroy())
+		}(id)
+	}
+	wg.Wait()
+	utils.AssertEqual(t, int64(n-n/2), store.ActiveCount())
+
+	// expired session detected during load
+	ctx = app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	ctx.Request().Header.SetCookie(store.CookieName, destroy[n-1])
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.ExpireNow())
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, int64(n-n/2-1), store.ActiveCount())
+
+	// loading the expired session again doesn't uncount it twice
+	for i := 0; i < 3; i++ {
+		sess, _ = store.Get(ctx)
+		utils.AssertEqual(t, true, sess.Fresh())
+	}
+	utils.AssertEqual(t, int64(n-n/2-1), store.ActiveCount())
+
+	// forged or unknown ids are not uncounted
+	for i := 0; i < 3; i++ {
+		ctx.Request().Header.SetCookie(store.CookieName, "forged")
+		sess, _ = store.Get(ctx)
+		utils.AssertEqual(t, true, sess.Fresh())
+	}
+	utils.AssertEqual(t, int64(n-n/2-1), store.ActiveCount())
+
+	// sessions the Storage removed are uncounted once
+	utils.AssertEqual(t, nil, store.Storage.Delete(destroy[n-2]))
+	for i := 0; i < 3; i++ {
+		ctx.Request().Header.SetCookie(store.CookieName, destroy[n-2])
+		sess, _ = store.Get(ctx)
+		utils.AssertEqual(t, true, sess.Fresh())
+	}
+	utils.AssertEqual(t, int64(n-n/2-2), store.ActiveCount())
+
+	utils.AssertEqual(t, nil, store.Reset())
+	utils.AssertEqual(t, int64(0), store.ActiveCount())
+}
+
+// go test -run Test_Session_ActiveCount_Idle_Expiry
+func Test_Session_ActiveCount_Idle_Expiry(t *testing.T) {
+	t.Parallel()
+	store := New(Config{Expiration: time.Second})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, int64(1), store.ActiveCount())
+
+	// idle sessions are uncounted without being loaded
+	time.Sleep(1100 * time.Millisecond)
+	utils.AssertEqual(t, int64(0), store.ActiveCount())
+
+	// a new session for the same cookie is counted once
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.Fresh())
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, int64(1), store.ActiveCount())
+}
+
+// go test -run Test_Session_OnModify
+func Test_Session_OnModify(t *testing.T) {
+	t.Parallel()
+
+	type change struct {
+		id, key string
+		value   interface{}
+	}
+	var changes []change
+	var sess *Session
+	store := New(Config{
+		OnModify: func(id, key string, value interface{}) {
+			// reading the session from the callback must not deadlock
+			_ = sess.Get(key)
+			changes = append(changes, change{id, key, value})
+		},
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ = store.Get(ctx)
+	sess.Set("name", "john")
+	sess.Delete("name")
+
+	utils.AssertEqual(t, 2, len(changes))
+	utils.AssertEqual(t, change{sess.ID(), "name", "john"}, changes[0])
+	utils.AssertEqual(t, change{sess.ID(), "name", nil}, changes[1])
+
+	// bulk writes report every key
+	changes = nil
+	sess.Set("name", "john")
+	sess.Replace(map[string]interface{}{"age": 42})
+	utils.AssertEqual(t, []change{
+		{sess.ID(), "name", "john"},
+		{sess.ID(), "name", nil},
+		{sess.ID(), "age", 42},
+	}, changes)
+
+	changes = nil
+	sess.WithLock(func(values map[string]interface{}) {
+		delete(values, "age")
+		values["admin"] = true
+	})
+	utils.AssertEqual(t, []change{
+		{sess.ID(), "age", nil},
+		{sess.ID(), "admin", true},
+	}, changes)
+}
+
+// go test -run Test_Session_SkipSave
+func Test_Session_SkipSave(t *testing.T) {
+	t.Parallel()
+	store := New(Config{
+		SkipSave: func(c *fiber.Ctx) bool {
+			return c.Method() == fiber.MethodGet
+		},
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// persist a session on a non skipped request
+	ctx.Method(fiber.MethodPost)
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// read works on a skipped request
+	ctx.Method(fiber.MethodGet)
+	ctx.Response().Header.DelCookie(store.CookieName)
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	// but nothing is written
+	sess.Set("name", "doe")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	// fresh sessions are neither stored nor cookied
+	ctx.Request().Header.DelCookie(store.CookieName)
+	sess, _ = store.Get(ctx)
+	id = sess.ID()
+	sess.Set("name", "jane")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, true, raw == nil)
+}
+
+// go test -race -run Test_Session_SetIfAbsent
+func Test_Session_SetIfAbsent(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+
+	const n = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			actual, set := sess.SetIfAbsent("seed", i)
+			mu.Lock()
+			defer mu.Unlock()
+			if set {
+				winners++
+				utils.AssertEqual(t, i, actual)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	utils.AssertEqual(t, 1, winners)
+	actual, set := sess.SetIfAbsent("seed", -1)
+	utils.AssertEqual(t, false, set)
+	utils.AssertEqual(t, sess.Get("seed"), actual)
+}
+
+// go test -run Test_Session_Cookie_Partitioned
+func Test_Session_Cookie_Partitioned(t *testing.T) {
+	t.Parallel()
+	store := New(Config{CookiePartitioned: true, CookieSameSite: "none"})
+	utils.AssertEqual(t, true, store.CookieSecure)
+
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	utils.AssertEqual(t, nil, sess.Save())
+
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+id+";"))
+	utils.AssertEqual(t, true, strings.Contains(cookie, "; secure"))
+	utils.AssertEqual(t, true, strings.Contains(cookie, "; SameSite=None"))
+	utils.AssertEqual(t, true, strings.HasSuffix(cookie, "; Partitioned"))
+	utils.AssertEqual(t, 1, strings.Count(ctx.Response().Header.String(), fiber.HeaderSetCookie+":"))
+
+	// the expired cookie is partitioned as well
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.Destroy())
+	cookie = string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.Contains(cookie, "max-age=-1") || strings.Contains(cookie, "expires="))
+	utils.AssertEqual(t, true, strings.HasSuffix(cookie, "; Partitioned"))
+	utils.AssertEqual(t, 1, strings.Count(ctx.Response().Header.String(), fiber.HeaderSetCookie+":"))
+}
+
+// go test -run Test_Session_Cookie_NoExpiry
+func Test_Session_Cookie_NoExpiry(t *testing.T) {
+	t.Parallel()
+	store := New(Config{NoExpiry: true, Expiration: time.Hour})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+id+";"))
+	utils.AssertEqual(t, false, strings.Contains(cookie, "max-age="))
+	utils.AssertEqual(t, false, strings.Contains(cookie, "expires="))
+
+	// the data is still stored on the server
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, true, len(raw) > 0)
+}
+
+// go test -run Test_Session_ExpireNow
+func Test_Session_ExpireNow(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// load the stored session with the cookie
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.ExpireNow())
+
+	// the same id now results in a fresh session
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+}
+
+// go test -run Test_Session_ExpireNow_Stored_Expiry
+func Test_Session_ExpireNow_Stored_Expiry(t *testing.T) {
+	t.Parallel()
+	storage := &persistentStorage{Storage: memory.New()}
+	store := New(Config{Storage: storage})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	other, _ := store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.ExpireNow())
+	utils.AssertEqual(t, true, storage.exp > 0)
+	utils.AssertEqual(t, true, sess.ExpiresIn() <= 0)
+
+	// the Storage still holds the data, but its expiry has passed
+	raw, _ := storage.Get(id)
+	utils.AssertEqual(t, true, raw != nil)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+	exists, err := store.Exists(id)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, exists)
+	utils.AssertEqual(t, nil, other.Refresh())
+	utils.AssertEqual(t, nil, other.Get("name"))
+}
+
+// persistentStorage never expires entries, like storages that ignore
+// the TTL, and records the last TTL it got
+type persistentStorage struct {
+	*memory.Storage
+	exp time.Duration
+}
+
+func (s *persistentStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.exp = exp
+	return s.Storage.Set(key, val, 0)
+}
+
+// go test -run Test_Session_Store_Exists
+func Test_Session_Store_Exists(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	exists, err := store.Exists(id)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, exists)
+
+	exists, err = store.Exists("missing")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, exists)
+
+	// expired sessions don't exist anymore
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.ExpireNow())
+	exists, err = store.Exists(id)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, exists)
+}
+
+// go test -run Test_Session_DataVersion
+func Test_Session_DataVersion(t *testing.T) {
+	t.Parallel()
+	storage := memory.New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// save v1 data
+	storeV1 := New(Config{Storage: storage, DataVersion: 1})
+	sess, _ := storeV1.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john doe")
+	utils.AssertEqual(t, nil, sess.Save())
+	ctx.Request().Header.SetCookie(storeV1.CookieName, id)
+
+	sess, _ = storeV1.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, []string{"name"}, sessionKeys(sess))
+
+	// load it with v2
+	var from int
+	storeV2 := New(Config{
+		Storage:     storage,
+		DataVersion: 2,
+		Migrate: func(old map[string]interface{}, fromVersion int) map[string]interface{} {
+			from = fromVersion
+			return map[string]interface{}{"first_name": strings.Fields(old["name"].(string))[0]}
+		},
+	})
+	sess, _ = storeV2.Get(ctx)
+	utils.AssertEqual(t, 1, from)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, "john", sess.Get("first_name"))
+	utils.AssertEqual(t, nil, sess.Get("name"))
+
+	// loading alone doesn't write the migrated data
+	from = 0
+	sess, _ = storeV2.Get(ctx)
+	utils.AssertEqual(t, 1, from)
+
+	// the migrated data is saved with the session
+	utils.AssertEqual(t, nil, sess.Save())
+	from = 0
+	sess, _ = storeV2.Get(ctx)
+	utils.AssertEqual(t, 0, from)
+	utils.AssertEqual(t, []string{"first_name"}, sessionKeys(sess))
+
+	// without Migrate the session starts over
+	storeV3 := New(Config{Storage: storage, DataVersion: 3})
+	sess, _ = storeV3.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, true, sess.Fresh())
+	utils.AssertEqual(t, nil, sess.Get("first_name"))
+}
+
+func sessionKeys(sess *Session) []string {
+	keys := make([]string, 0, sess.data.Len())
+	for key := range sess.data.Data {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// go test -run Test_Session_ReadOnly
+func Test_Session_ReadOnly(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+
+	// a pure read succeeds
+	ReadOnly(ctx)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// writes are dropped and reported by Save
+	sess, _ = store.Get(ctx)
+	sess.Set("name", "doe")
+	sess.Delete("name")
+	_, set := sess.SetIfAbsent("age", 1)
+	utils.AssertEqual(t, false, set)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, ErrReadOnly, sess.Save())
+
+	// or panic
+	store.ReadOnlyPanic = true
+	sess, _ = store.Get(ctx)
+	func() {
+		defer func() {
+			utils.AssertEqual(t, ErrReadOnly, recover())
+		}()
+		sess.Set("name", "doe")
+	}()
+	utils.AssertEqual(t, "john", sess.Get("name"))
+}
+
+// go test -run Test_Session_Refresh
+func Test_Session_Refresh(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// first handler
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	sess.Set("local", true)
+
+	// second writer
+	other := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(other)
+	other.Request().Header.SetCookie(store.CookieName, id)
+	otherSess, _ := store.Get(other)
+	otherSess.Set("name", "doe")
+	utils.AssertEqual(t, nil, otherSess.Save())
+
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Refresh())
+	utils.AssertEqual(t, "doe", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Get("local"))
+}
+
+// go test -run Test_Session_BindStruct
+func Test_Session_BindStruct(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	type prefs struct {
+		Theme    string   `session:"theme"`
+		Size     int      `session:"size"`
+		Beta     bool     `session:"beta"`
+		Tags     []string `session:"tags"`
+		Lang     string   `session:"lang"`
+		Ignored  string   `session:"-"`
+		Untagged string
+	}
+
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.StoreStruct(prefs{
+		Theme:    "dark",
+		Size:     14,
+		Beta:     true,
+		Tags:     []string{"a", "b"},
+		Lang:     "en",
+		Ignored:  "x",
+		Untagged: "y",
+	}))
+	utils.AssertEqual(t, 5, sess.data.Len())
+	sess.Delete("lang")
+
+	// a missing key keeps the default
+	out := prefs{Lang: "de"}
+	utils.AssertEqual(t, nil, sess.BindStruct(&out))
+	utils.AssertEqual(t, prefs{Theme: "dark", Size: 14, Beta: true, Tags: []string{"a", "b"}, Lang: "de"}, out)
+
+	// type mismatch
+	sess.Set("size", "14")
+	err := sess.BindStruct(&out)
+	utils.AssertEqual(t, `session: cannot bind "size" of type string to field Size of type int`, err.Error())
+
+	// invalid arguments
+	utils.AssertEqual(t, true, sess.BindStruct(out) != nil)
+	utils.AssertEqual(t, true, sess.StoreStruct("theme") != nil)
+}
+
+// go test -run Test_Session_CookieConfig
+func Test_Session_CookieConfig(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	newCookie := func(store *Store) *fasthttp.Cookie {
+		ctx.Response().Header.DelCookie(store.CookieName)
+		sess, _ := store.Get(ctx)
+		utils.AssertEqual(t, nil, sess.Save())
+		cookie := fasthttp.AcquireCookie()
+		utils.AssertEqual(t, nil, cookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+		return cookie
+	}
+
+	// grouped config
+	store := New(Config{Cookie: CookieConfig{
+		Domain:   "example.com",
+		Path:     "/app",
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: "Strict",
+		MaxAge:   time.Hour,
+	}})
+	cookie := newCookie(store)
+	utils.AssertEqual(t, "example.com", string(cookie.Domain()))
+	utils.AssertEqual(t, "/app", string(cookie.Path()))
+	utils.AssertEqual(t, true, cookie.Secure())
+	utils.AssertEqual(t, true, cookie.HTTPOnly())
+	utils.AssertEqual(t, fasthttp.CookieSameSiteStrictMode, cookie.SameSite())
+	utils.AssertEqual(t, 3600, cookie.MaxAge())
+	fasthttp.ReleaseCookie(cookie)
+
+	// legacy flat fields
+	store = New(Config{
+		CookieDomain:   "example.com",
+		CookiePath:     "/app",
+		CookieSecure:   true,
+		CookieHTTPOnly: true,
+		CookieSameSite: "Strict",
+	})
+	utils.AssertEqual(t, CookieConfig{
+		Domain:   "example.com",
+		Path:     "/app",
+		Secure:   true,
+		HTTPOnly: true,
+		SameSite: "Strict",
+		MaxAge:   ConfigDefault.Expiration,
+	}, store.Cookie)
+	cookie = newCookie(store)
+	utils.AssertEqual(t, "example.com", string(cookie.Domain()))
+	utils.AssertEqual(t, "/app", string(cookie.Path()))
+	utils.AssertEqual(t, true, cookie.Secure())
+	utils.AssertEqual(t, true, cookie.HTTPOnly())
+	utils.AssertEqual(t, fasthttp.CookieSameSiteStrictMode, cookie.SameSite())
+	utils.AssertEqual(t, int(ConfigDefault.Expiration.Seconds()), cookie.MaxAge())
+	fasthttp.ReleaseCookie(cookie)
+
+	// the grouped config takes precedence
+	store = New(Config{
+		CookieDomain:   "legacy.com",
+		CookiePath:     "/legacy",
+		CookieSameSite: "None",
+		Cookie: CookieConfig{
+			Domain:      "example.com",
+			Path:        "/app",
+			SameSite:    "Strict",
+			SessionOnly: true,
+		},
+	})
+	utils.AssertEqual(t, "example.com", store.CookieDomain)
+	cookie = newCookie(store)
+	utils.AssertEqual(t, "example.com", string(cookie.Domain()))
+	utils.AssertEqual(t, "/app", string(cookie.Path()))
+	utils.AssertEqual(t, fasthttp.CookieSameSiteStrictMode, cookie.SameSite())
+	utils.AssertEqual(t, 0, cookie.MaxAge())
+	fasthttp.ReleaseCookie(cookie)
+}
+
+// go test -run Test_Session_Cookie_ExpiryBuffer
+func Test_Session_Cookie_ExpiryBuffer(t *testing.T) {
+	t.Parallel()
+	storage := &expiryStorage{Storage: memory.New()}
+	store := New(Config{
+		Storage:    storage,
+		Expiration: time.Hour,
+		Cookie:     CookieConfig{ExpiryBuffer: 5 * time.Minute},
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	utils.AssertEqual(t, nil, cookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, 3900, cookie.MaxAge())
+	utils.AssertEqual(t, time.Hour, storage.exp)
+}
+
+type expiryStorage struct {
+	*memory.Storage
+	exp time.Duration
+}
+
+func (s *expiryStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.exp = exp
+	return s.Storage.Set(key, val, exp)
+}
+
+// go test -run Test_Session_Store_Ping
+func Test_Session_Store_Ping(t *testing.T) {
+	t.Parallel()
+
+	// healthy storage
+	storage := memory.New()
+	store := New(Config{
+		Storage:      storage,
+		KeyGenerator: func() string { return "probe" },
+	})
+	utils.AssertEqual(t, nil, store.Ping())
+	// the sentinel is removed again
+	raw, err := storage.Get(pingKey + "probe")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, raw == nil)
+
+	// failing storage
+	errDown := errors.New("connection refused")
+	store = New(Config{Storage: &failingStorage{Storage: memory.New(), err: errDown}})
+	utils.AssertEqual(t, errDown, store.Ping())
+
+	// storage that drops writes
+	store = New(Config{Storage: &failingStorage{Storage: memory.New()}})
+	utils.AssertEqual(t, ErrStorageUnreachable, store.Ping())
+
+	// storage with its own ping
+	store = New(Config{Storage: &pingStorage{Storage: memory.New(), err: errDown}})
+	utils.AssertEqual(t, errDown, store.Ping())
+}
+
+type failingStorage struct {
+	*memory.Storage
+	err error
+}
+
+func (s *failingStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.err
+}
+
+type pingStorage struct {
+	*memory.Storage
+	err error
+}
+
+func (s *pingStorage) Ping() error {
+	return s.err
+}
+
+// go test -race -run Test_Session_Values
+func Test_Session_Values(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "john")
+	sess.Set("age", 42)
+
+	values := sess.Values()
+	utils.AssertEqual(t, map[string]interface{}{"name": "john", "age": 42}, values)
+
+	// the copy is detached from the session
+	values["name"] = "doe"
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			sess.Set("count", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		for key := range sess.Values() {
+			_ = key
+		}
+	}
+	wg.Wait()
+	utils.AssertEqual(t, 99, sess.Get("count"))
+}
+
+// go test -race -run Test_Session_Replace
+func Test_Session_Replace(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("stale", true)
+
+	oldState := map[string]interface{}{"a": 1, "b": 1}
+	newState := map[string]interface{}{"a": 2, "b": 2}
+	sess.Replace(oldState)
+	utils.AssertEqual(t, oldState, sess.Values())
+
+	// the map is copied in
+	oldState["a"] = 3
+	utils.AssertEqual(t, 1, sess.Get("a"))
+	oldState["a"] = 1
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if i%2 == 0 {
+				sess.Replace(newState)
+			} else {
+				sess.Replace(oldState)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		values := sess.Values()
+		utils.AssertEqual(t, 2, len(values))
+		utils.AssertEqual(t, values["a"], values["b"])
+	}
+	wg.Wait()
+	utils.AssertEqual(t, oldState, sess.Values())
+}
+
+// go test -run Test_Session_Store_Save
+func Test_Session_Store_Save(t *testing.T) {
+	t.Parallel()
+	store := New(Config{DataVersion: 2})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	utils.AssertEqual(t, nil, store.Save("admin-id", map[string]interface{}{"name": "john", "age": 42}, time.Hour))
+
+	// load it through the request flow
+	ctx.Request().Header.SetCookie(store.CookieName, "admin-id")
+	sess, err := store.Get(ctx)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, map[string]interface{}{"name": "john", "age": 42}, sess.Values())
+}
+
+// go test -run Test_Session_LazyCreate
+func Test_Session_LazyCreate(t *testing.T) {
+	t.Parallel()
+	storage := memory.New()
+	store := New(Config{Storage: storage, LazyCreate: true})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// a read-only request creates nothing
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, "", sess.ID())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, int64(0), store.ActiveCount())
+
+	// a write creates the session
+	sess, _ = store.Get(ctx)
+	sess.Set("name", "john")
+	id := sess.ID()
+	utils.AssertEqual(t, 36, len(id))
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+id+";"))
+	raw, _ := storage.Get(id)
+	utils.AssertEqual(t, true, len(raw) > 0)
+}
+
+// go test -run Test_Session_Meta
+func Test_Session_Meta(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.SetMeta("ip", "0.0.0.0")
+	sess.SetMeta("login", "password")
+	sess.Set("ip", "user value")
+	utils.AssertEqual(t, "0.0.0.0", sess.GetMeta("ip"))
+	utils.AssertEqual(t, map[string]interface{}{"ip": "user value"}, sess.Values())
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// meta is persisted apart from the values
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "0.0.0.0", sess.GetMeta("ip"))
+	utils.AssertEqual(t, "password", sess.GetMeta("login"))
+	utils.AssertEqual(t, "", sess.GetMeta("missing"))
+	utils.AssertEqual(t, map[string]interface{}{"ip": "user value"}, sess.Values())
+
+	// and survives even without values
+	sess.Delete("ip")
+	utils.AssertEqual(t, nil, sess.Save())
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "password", sess.GetMeta("login"))
+	utils.AssertEqual(t, 0, len(sess.Values()))
+}
+
+// go test -run Test_Session_Reserved_Values
+func Test_Session_Reserved_Values(t *testing.T) {
+	t.Parallel()
+	store := New()
+	store.RegisterType(map[string]string{})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// values can't forge the metadata or timestamps
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	forged := map[string]string{"sudo_until": "4000000000000000000"}
+	sess.Set("__session_meta", forged)
+	sess.Set("__session_expires", int64(1))
+	sess.SetMeta("sudo_until", "4000000000000000000")
+	sess.SetMeta("SudoUntil", "4000000000000000000")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, false, sess.IsSudo())
+	utils.AssertEqual(t, forged, sess.Get("__session_meta"))
+	utils.AssertEqual(t, int64(1), sess.Get("__session_expires"))
+	utils.AssertEqual(t, true, sess.ExpiresIn() > time.Hour)
+
+	// sessions saved as plain values are still loaded
+	var buf bytes.Buffer
+	utils.AssertEqual(t, nil, gob.NewEncoder(&buf).Encode(map[string]interface{}{"name": "john"}))
+	utils.AssertEqual(t, nil, store.Storage.Set(id, buf.Bytes(), 0))
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	utils.AssertEqual(t, map[string]interface{}{"name": "john"}, sess.Values())
+}
+
+// go test -run Test_Session_Cookie_Accessor
+func Test_Session_Cookie_Accessor(t *testing.T) {
+	t.Parallel()
+	store := New(Config{
+		CookiePath:     "/app",
+		CookieSameSite: "strict",
+		CookieSecure:   true,
+		Expiration:     time.Hour,
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	cookie := sess.Cookie()
+	utils.AssertEqual(t, store.CookieName, cookie.Name)
+	utils.AssertEqual(t, sess.ID(), cookie.Value)
+	utils.AssertEqual(t, "/app", cookie.Path)
+	utils.AssertEqual(t, "Strict", cookie.SameSite)
+	utils.AssertEqual(t, true, cookie.Secure)
+	utils.AssertEqual(t, 3600, cookie.MaxAge)
+
+	// the emitted cookie matches
+	utils.AssertEqual(t, nil, sess.Save())
+	fcookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(fcookie)
+	utils.AssertEqual(t, nil, fcookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, cookie.Value, string(fcookie.Value()))
+	utils.AssertEqual(t, cookie.Path, string(fcookie.Path()))
+	utils.AssertEqual(t, fasthttp.CookieSameSiteStrictMode, fcookie.SameSite())
+	utils.AssertEqual(t, cookie.MaxAge, fcookie.MaxAge())
+
+	// changes are applied to the emitted cookie
+	ctx.Response().Header.DelCookie(store.CookieName)
+	sess, _ = store.Get(ctx)
+	cookie = sess.Cookie()
+	cookie.Path = "/admin"
+	cookie.SameSite = "none"
+	cookie.MaxAge = 60
+	cookie.Expires = time.Now().Add(time.Minute)
+	utils.AssertEqual(t, nil, sess.Save())
+	fcookie.Reset()
+	utils.AssertEqual(t, nil, fcookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, "/admin", string(fcookie.Path()))
+	utils.AssertEqual(t, fasthttp.CookieSameSiteNoneMode, fcookie.SameSite())
+	utils.AssertEqual(t, 60, fcookie.MaxAge())
+
+	// the value carries the current id
+	ctx.Response().Header.DelCookie(store.CookieName)
+	sess, _ = store.Get(ctx)
+	cookie = sess.Cookie()
+	cookie.Value = "forged"
+	utils.AssertEqual(t, nil, sess.Regenerate())
+	id := sess.ID()
+	EmitCookie(ctx, sess)
+	fcookie.Reset()
+	utils.AssertEqual(t, nil, fcookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, id, string(fcookie.Value()))
+}
+
+// go test -run Test_Session_ExpiresIn
+func Test_Session_ExpiresIn(t *testing.T) {
+	t.Parallel()
+	store := New(Config{Expiration: time.Hour})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// fresh
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, time.Hour, sess.ExpiresIn())
+
+	// half-elapsed
+	utils.AssertEqual(t, nil, store.Save("half", map[string]interface{}{"name": "john"}, 30*time.Minute))
+	ctx.Request().Header.SetCookie(store.CookieName, "half")
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, false, sess.Fresh())
+	left := sess.ExpiresIn()
+	utils.AssertEqual(t, true, left > 29*time.Minute && left <= 30*time.Minute, left.String())
+	utils.AssertEqual(t, map[string]interface{}{"name": "john"}, sess.Values())
+
+	// expired
+	utils.AssertEqual(t, nil, sess.ExpireNow())
+	utils.AssertEqual(t, true, sess.ExpiresIn() <= 0)
+}
+
+// go test -run Test_Session_Store_Load
+func Test_Session_Store_Load(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	var id string
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, isNew, err := store.Load(c)
+		if err != nil {
+			return err
+		}
+		id = sess.ID()
+		sess.Set("visited", true)
+		if err := sess.Save(); err != nil {
+			return err
+		}
+		return c.SendString(strconv.FormatBool(isNew))
+	})
+
+	// first visit
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	body, err := ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "true", string(body))
+	firstID := id
+
+	// returning visit
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderCookie, store.CookieName+"="+firstID)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	body, err = ioutil.ReadAll(resp.Body)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "false", string(body))
+	utils.AssertEqual(t, firstID, id)
+}
+
+// go test -run Test_Session_Store_DebugDump
+func Test_Session_Store_DebugDump(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	development := true
+	app.Use(store.DebugDump(func(c *fiber.Ctx) bool {
+		return development && c.Query("debug") == "1"
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("name", "john")
+		return sess.Save()
+	})
+	app.Get("/peek", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	// not requested
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(DebugHeader))
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/?debug=1", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	raw := resp.Header.Get(DebugHeader)
+	utils.AssertEqual(t, false, strings.Contains(raw, "john"))
+	var summary struct {
+		ID        string         `json:"id"`
+		Keys      map[string]int `json:"keys"`
+		ExpiresIn int64          `json:"expires_in"`
+		Age       int64          `json:"age"`
+	}
+	utils.AssertEqual(t, nil, json.Unmarshal([]byte(raw), &summary))
+	utils.AssertEqual(t, true, summary.ID != "")
+	utils.AssertEqual(t, 1, len(summary.Keys))
+	utils.AssertEqual(t, true, summary.Keys["name"] > 0)
+	utils.AssertEqual(t, true, summary.ExpiresIn > int64((store.Expiration-time.Minute)/time.Second))
+	utils.AssertEqual(t, int64(0), summary.Age)
+
+	// requests without a session cookie are not dumped
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/peek?debug=1", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(DebugHeader))
+	utils.AssertEqual(t, int64(2), store.ActiveCount())
+
+	// the session of the request is only read
+	req := httptest.NewRequest(fiber.MethodGet, "/peek?debug=1", nil)
+	req.Header.Set(fiber.HeaderCookie, store.CookieName+"="+summary.ID)
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(fiber.HeaderSetCookie))
+	summary.Keys = nil
+	utils.AssertEqual(t, nil, json.Unmarshal([]byte(resp.Header.Get(DebugHeader)), &summary))
+	utils.AssertEqual(t, 1, len(summary.Keys))
+
+	// unknown ids are reported as fresh and not stored
+	req = httptest.NewRequest(fiber.MethodGet, "/peek?debug=1", nil)
+	req.Header.Set(fiber.HeaderCookie, store.CookieName+"=unknown")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, `{"id":"unknown","fresh":true,"keys":{},"expires_in":86400,"age":0}`, resp.Header.Get(DebugHeader))
+	exists, err := store.Exists("unknown")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, exists)
+
+	// never in production
+	development = false
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/?debug=1", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(DebugHeader))
+
+	// a nil gate never activates
+	app = fiber.New()
+	app.Use(store.DebugDump(nil))
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/?debug=1", nil))
+	utils.AssertEqual(t, nil, err, "app.Test(req)")
+	utils.AssertEqual(t, "", resp.Header.Get(DebugHeader))
+}
+
+// go test -run Test_Session_Delete
+func Test_Session_Delete(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, true, sess.Delete("name"))
+	utils.AssertEqual(t, nil, sess.Get("name"))
+	utils.AssertEqual(t, false, sess.Delete("name"))
+	utils.AssertEqual(t, false, sess.Delete("missing"))
+}
+
+// go test -run Test_Session_RotateInterval
+func Test_Session_RotateInterval(t *testing.T) {
+	t.Parallel()
+	store := New(Config{
+		RotateInterval: 10 * time.Minute,
+		SkipSave: func(c *fiber.Ctx) bool {
+			return c.Get("X-Skip") != ""
+		},
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// a session that was rotated recently keeps its id
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	ctx.Response().Header.DelCookie(store.CookieName)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+
+	// move the last rotation past the interval
+	var buf bytes.Buffer
+	utils.AssertEqual(t, nil, gob.NewEncoder(&buf).Encode(&record{
+		Data:    map[string]interface{}{"name": "john"},
+		Expires: time.Now().Add(time.Hour).Unix(),
+		Rotated: time.Now().Add(-11 * time.Minute).Unix(),
+	}))
+	utils.AssertEqual(t, nil, store.Storage.Set(id, buf.Bytes(), 0))
+
+	// skipped and read-only requests don't rotate
+	for _, skip := range []func(c *fiber.Ctx){
+		func(c *fiber.Ctx) { c.Request().Header.Set("X-Skip", "1") },
+		ReadOnly,
+	} {
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		ctx.Request().Header.SetCookie(store.CookieName, id)
+		skip(ctx)
+		sess, _ = store.Get(ctx)
+		utils.AssertEqual(t, id, sess.ID())
+		EmitCookie(ctx, sess)
+		_ = sess.Save()
+		utils.AssertEqual(t, true, strings.HasPrefix(string(ctx.Response().Header.PeekCookie(store.CookieName)), store.CookieName+"="+id+";"))
+		raw, _ := store.Storage.Get(id)
+		utils.AssertEqual(t, buf.Bytes(), raw)
+		app.ReleaseCtx(ctx)
+	}
+
+	// the new id is handed out on save
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	utils.AssertEqual(t, nil, cookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+	newID := string(cookie.Value())
+	utils.AssertEqual(t, false, id == newID)
+
+	// the data moved to the new id
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, 0, len(raw))
+	ctx.Request().Header.SetCookie(store.CookieName, newID)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, newID, sess.ID())
+	utils.AssertEqual(t, "john", sess.Get("name"))
+}
+
+// go test -run Test_Session_ExpirationHeader
+func Test_Session_ExpirationHeader(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	ctx.Request().Header.Set("X-Session-Expiration", "30s")
+
+	// honored when enabled
+	storage := &expiryStorage{Storage: memory.New()}
+	store := New(Config{Storage: storage, ExpirationHeader: "X-Session-Expiration"})
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 30*time.Second, storage.exp)
+
+	// invalid values are ignored
+	for _, exp := range []string{"soon", "-30s", "0s", "87600h"} {
+		ctx.Request().Header.Set("X-Session-Expiration", exp)
+		sess, _ = store.Get(ctx)
+		sess.Set("name", "john")
+		utils.AssertEqual(t, nil, sess.Save())
+		utils.AssertEqual(t, ConfigDefault.Expiration, storage.exp, exp)
+	}
+
+	// ignored when disabled
+	ctx.Request().Header.Set("X-Session-Expiration", "30s")
+	storage = &expiryStorage{Storage: memory.New()}
+	store = New(Config{Storage: storage})
+	sess, _ = store.Get(ctx)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, ConfigDefault.Expiration, storage.exp)
+}
+
+// go test -run Test_Session_MaxTTL
+func Test_Session_MaxTTL(t *testing.T) {
+	t.Parallel()
+	storage := &expiryStorage{Storage: memory.New()}
+	store := New(Config{Storage: storage, Expiration: 48 * time.Hour, MaxTTL: time.Hour})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, time.Hour, storage.exp)
+
+	utils.AssertEqual(t, nil, store.Save("admin-id", map[string]interface{}{"name": "john"}, 72*time.Hour))
+	utils.AssertEqual(t, time.Hour, storage.exp)
+
+	// shorter expirations are kept
+	utils.AssertEqual(t, nil, store.Save("admin-id", map[string]interface{}{"name": "john"}, time.Minute))
+	utils.AssertEqual(t, time.Minute, storage.exp)
+}
+
+// go test -run Test_Session_BindClientCert
+func Test_Session_BindClientCert(t *testing.T) {
+	t.Parallel()
+	store := New(Config{BindClientCert: true})
+	app := fiber.New()
+
+	// simulate the client certificate with a request header
+	store.fingerprint = func(c *fiber.Ctx) string {
+		return c.Get("X-Cert")
+	}
+	load := func(cert, id string) *Session {
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		ctx.Request().Header.Set("X-Cert", cert)
+		ctx.Request().Header.SetCookie(store.CookieName, id)
+		sess, err := store.Get(ctx)
+		utils.AssertEqual(t, nil, err)
+		return sess
+	}
+
+	// bind to the certificate on save
+	sess := load("cert-a", "")
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	// matching certificate
+	sess = load("cert-a", id)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	// mismatching and missing certificate
+	for _, cert := range []string{"cert-b", ""} {
+		sess = load(cert, id)
+		utils.AssertEqual(t, false, id == sess.ID())
+		utils.AssertEqual(t, true, sess.Fresh())
+		utils.AssertEqual(t, nil, sess.Get("name"))
+	}
+
+	// the bound session is left intact
+	sess = load("cert-a", id)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	// the metadata can't remove the binding
+	sess.SetMeta("client_cert", "")
+	sess.SetMeta("ClientCert", "")
+	utils.AssertEqual(t, nil, sess.Save())
+	sess = load("cert-b", id)
+	utils.AssertEqual(t, false, id == sess.ID())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+
+	// the replaced id is reported as new
+	for cert, isNew := range map[string]bool{"cert-a": false, "cert-b": true} {
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		ctx.Request().Header.Set("X-Cert", cert)
+		ctx.Request().Header.SetCookie(store.CookieName, id)
+		sess, loaded, err := store.Load(ctx)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, isNew, loaded, cert)
+		utils.AssertEqual(t, isNew, sess.ID() != id, cert)
+		app.ReleaseCtx(ctx)
+	}
+
+	// sessions saved without a certificate stay unbound
+	sess = load("", "")
+	id = sess.ID()
+	sess.Set("name", "doe")
+	utils.AssertEqual(t, nil, sess.Save())
+	sess = load("", id)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, "doe", sess.Get("name"))
+}
+
+// go test -run Test_Session_BindClientCert_RotateInterval
+func Test_Session_BindClientCert_RotateInterval(t *testing.T) {
+	t.Parallel()
+	store := New(Config{BindClientCert: true, RotateInterval: 10 * time.Minute})
+	app := fiber.New()
+
+	// simulate the client certificate with a request header
+	store.fingerprint = func(c *fiber.Ctx) string {
+		return c.Get("X-Cert")
+	}
+	load := func(cert, id string) *Session {
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		ctx.Request().Header.Set("X-Cert", cert)
+		ctx.Request().Header.SetCookie(store.CookieName, id)
+		sess, err := store.Get(ctx)
+		utils.AssertEqual(t, nil, err)
+		return sess
+	}
+
+	// a bound session that is due for rotation
+	const id = "victim"
+	var buf bytes.Buffer
+	utils.AssertEqual(t, nil, gob.NewEncoder(&buf).Encode(&record{
+		Data:       map[string]interface{}{"name": "john"},
+		Expires:    time.Now().Add(time.Hour).Unix(),
+		Rotated:    time.Now().Add(-11 * time.Minute).Unix(),
+		ClientCert: "cert-a",
+	}))
+	utils.AssertEqual(t, nil, store.Storage.Set(id, buf.Bytes(), 0))
+
+	// another certificate neither rotates nor moves the session
+	sess := load("cert-b", id)
+	utils.AssertEqual(t, false, id == sess.ID())
+	utils.AssertEqual(t, nil, sess.Get("name"))
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, buf.Bytes(), raw)
+
+	// the owner still gets the session rotated
+	sess = load("cert-a", id)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+	utils.AssertEqual(t, nil, sess.Save())
+	raw, _ = store.Storage.Get(id)
+	utils.AssertEqual(t, 0, len(raw))
+}
+
+// go test -run Test_Session_ManualCookie
+func Test_Session_ManualCookie(t *testing.T) {
+	t.Parallel()
+	store := New(Config{ManualCookie: true})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// the data is saved without a cookie
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, true, len(raw) > 0)
+
+	// until the handler emits it
+	sess, _ = store.Get(ctx)
+	id = sess.ID()
+	sess.Set("name", "john")
+	EmitCookie(ctx, sess)
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+id+";"))
+}
+
+// go test -run Test_Session_ManualCookie_RotateInterval
+func Test_Session_ManualCookie_RotateInterval(t *testing.T) {
+	t.Parallel()
+	store := New(Config{ManualCookie: true, RotateInterval: 10 * time.Minute})
+	app := fiber.New()
+
+	// a session past its RotateInterval
+	id := "rotate-me"
+	var buf bytes.Buffer
+	utils.AssertEqual(t, nil, gob.NewEncoder(&buf).Encode(&record{
+		Data:    map[string]interface{}{"name": "john"},
+		Expires: time.Now().Add(time.Hour).Unix(),
+		Rotated: time.Now().Add(-11 * time.Minute).Unix(),
+	}))
+	utils.AssertEqual(t, nil, store.Storage.Set(id, buf.Bytes(), 0))
+
+	// Save alone keeps the id the client knows
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ := store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, len(ctx.Response().Header.PeekCookie(store.CookieName)))
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, id, sess.ID())
+	utils.AssertEqual(t, "john", sess.Get("name"))
+
+	// EmitCookie applies the rotation
+	EmitCookie(ctx, sess)
+	newID := sess.ID()
+	utils.AssertEqual(t, false, id == newID)
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+newID+";"))
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, 0, len(raw))
+	ctx.Request().Header.SetCookie(store.CookieName, newID)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, "john", sess.Get("name"))
+}
+
+// go test -run Test_Session_Age
+func Test_Session_Age(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	// fresh
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	utils.AssertEqual(t, time.Duration(0), sess.Age())
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.Age() < time.Minute)
+
+	// aged, the creation survives saving
+	var buf bytes.Buffer
+	utils.AssertEqual(t, nil, gob.NewEncoder(&buf).Encode(&record{
+		Data:    map[string]interface{}{"name": "john"},
+		Expires: time.Now().Add(time.Hour).Unix(),
+		Created: time.Now().Add(-2 * time.Hour).Unix(),
+	}))
+	utils.AssertEqual(t, nil, store.Storage.Set(id, buf.Bytes(), 0))
+	for i := 0; i < 2; i++ {
+		sess, _ = store.Get(ctx)
+		age := sess.Age()
+		utils.AssertEqual(t, true, age >= 2*time.Hour && age < 2*time.Hour+time.Minute, age.String())
+		utils.AssertEqual(t, nil, sess.Save())
+	}
+}
+
+// go test -run Test_Session_AuditFunc
+func Test_Session_AuditFunc(t *testing.T) {
+	t.Parallel()
+	var events []string
+	store := New(Config{
+		AuditFunc: func(id, op, key string) {
+			events = append(events, id+" "+op+" "+key)
+		},
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	sess.Get("name")
+	sess.SetIfAbsent("age", 42)
+	sess.Delete("name")
+	utils.AssertEqual(t, []string{
+		id + " write name",
+		id + " read name",
+		id + " write age",
+		id + " delete name",
+	}, events)
+
+	// bulk reads and writes report their keys
+	events = nil
+	sess.Values()
+	var user struct {
+		Age int `session:"age"`
+	}
+	utils.AssertEqual(t, nil, sess.BindStruct(&user))
+	sess.Replace(map[string]interface{}{"name": "doe"})
+	sess.WithLock(func(values map[string]interface{}) {
+		values["name"] = "john"
+	})
+	utils.AssertEqual(t, []string{
+		id + " read age",
+		id + " read age",
+		id + " delete age",
+		id + " write name",
+		id + " write *",
+	}, events)
+}
+
+// go test -run Test_Session_IDHeader
+func Test_Session_IDHeader(t *testing.T) {
+	t.Parallel()
+	store := New(Config{IDHeader: "X-Session-Id"})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	utils.AssertEqual(t, nil, sess.Save())
+	cookie := string(ctx.Response().Header.PeekCookie(store.CookieName))
+	utils.AssertEqual(t, true, strings.HasPrefix(cookie, store.CookieName+"="+id+";"))
+	utils.AssertEqual(t, id, string(ctx.Response().Header.Peek("X-Session-Id")))
+
+	// the header is written for existing sessions too
+	ctx.Response().Header.Del("X-Session-Id")
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, id, string(ctx.Response().Header.Peek("X-Session-Id")))
+}
+
+// go test -run Test_Session_Sudo
+func Test_Session_Sudo(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	utils.AssertEqual(t, false, sess.IsSudo())
+
+	// granted and persisted
+	sess.Sudo(time.Minute)
+	utils.AssertEqual(t, true, sess.IsSudo())
+	utils.AssertEqual(t, nil, sess.Save())
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.IsSudo())
+
+	// cleared on regenerate
+	utils.AssertEqual(t, nil, sess.Regenerate())
+	utils.AssertEqual(t, false, sess.IsSudo())
+
+	// the window expires
+	sess.Sudo(10 * time.Millisecond)
+	utils.AssertEqual(t, true, sess.IsSudo())
+	time.Sleep(20 * time.Millisecond)
+	utils.AssertEqual(t, false, sess.IsSudo())
+}
+
+// go test -run Test_Session_Remember
+func Test_Session_Remember(t *testing.T) {
+	t.Parallel()
+	store := New(Config{
+		Expiration: time.Hour,
+		NoExpiry:   true,
+	})
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	maxAge := func() int {
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		utils.AssertEqual(t, nil, cookie.ParseBytes(ctx.Response().Header.PeekCookie(store.CookieName)))
+		return cookie.MaxAge()
+	}
+
+	// remember extends the expiry and the cookie
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+	sess.Set("user", "john")
+	sess.Remember(30 * 24 * time.Hour)
+	utils.AssertEqual(t, 30*24*time.Hour, sess.ExpiresIn())
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 30*24*3600, maxAge())
+
+	// persisted across reloads
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.ExpiresIn() > 29*24*time.Hour)
+	utils.AssertEqual(t, nil, sess.Save())
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.ExpiresIn() > 29*24*time.Hour)
+
+	// forget reverts to the short default
+	sess.Forget()
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, maxAge())
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.ExpiresIn() <= time.Hour)
+
+	// the metadata can't change the lifetime
+	sess.SetMeta("remember", strconv.FormatInt(int64(100*365*24*time.Hour), 10))
+	utils.AssertEqual(t, nil, sess.Save())
+	utils.AssertEqual(t, 0, maxAge())
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, true, sess.ExpiresIn() <= time.Hour)
+}
+
+// go test -race -run Test_Session_Append
+func Test_Session_Append(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	id := sess.ID()
+
+	// a non-list value is replaced
+	sess.Set("recent", "product-0")
+	sess.Append("recent", "product-1", 3)
+	utils.AssertEqual(t, []interface{}{"product-1"}, sess.Get("recent"))
+	sess.Append("recent", "product-2", 3)
+	sess.Append("recent", "product-3", 3)
+	sess.Append("recent", "product-4", 3)
+	utils.AssertEqual(t, []interface{}{"product-2", "product-3", "product-4"}, sess.Get("recent"))
+
+	// survives a save
+	utils.AssertEqual(t, nil, sess.Save())
+	ctx.Request().Header.SetCookie(store.CookieName, id)
+	sess, _ = store.Get(ctx)
+	utils.AssertEqual(t, []interface{}{"product-2", "product-3", "product-4"}, sess.Get("recent"))
+
+	// other slices keep their elements
+	sess.Set("tags", []string{"a"})
+	sess.Append("tags", "b", 0)
+	sess.Append("tags", 5, 0)
+	utils.AssertEqual(t, []interface{}{"a", "b", 5}, sess.Get("tags"))
+	sess.Set("ids", []int{1, 2, 3})
+	sess.Append("ids", 4, 3)
+	utils.AssertEqual(t, []interface{}{2, 3, 4}, sess.Get("ids"))
+
+	// concurrent appends
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess.Append("all", i, 0)
+			sess.Append("bounded", i, 10)
+		}(i)
+	}
+	wg.Wait()
+	utils.AssertEqual(t, 50, len(sess.Get("all").([]interface{})))
+	utils.AssertEqual(t, 10, len(sess.Get("bounded").([]interface{})))
+}
+
+// go test -race -run Test_Session_WithLock
+func Test_Session_WithLock(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	sess.Set("debit", 0)
+	sess.Set("credit", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			sess.WithLock(func(values map[string]interface{}) {
+				values["debit"] = values["debit"].(int) + 1
+				values["credit"] = values["credit"].(int) - 1
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			// readers never see a half applied update
+			values := sess.Values()
+			utils.AssertEqual(t, 0, values["debit"].(int)+values["credit"].(int))
+		}()
+	}
+	wg.Wait()
+	utils.AssertEqual(t, 50, sess.Get("debit"))
+	utils.AssertEqual(t, -50, sess.Get("credit"))
+}
+
+// go test -run Test_Session_SizeBytes
+func Test_Session_SizeBytes(t *testing.T) {
+	t.Parallel()
+	store := New()
+	app := fiber.New()
+
+	// fiber context
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, _ := store.Get(ctx)
+	size, err := sess.SizeBytes()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 0, size)
+
+	payloads := []map[string]interface{}{
+		{"name": "john"},
+		{"name": "john", "age": 42, "admin": true},
+		{"blob": strings.Repeat("x", 4096)},
+	}
+	for _, payload := range payloads {
+		sess, _ = store.Get(ctx)
+		id := sess.ID()
+		for k, v := range payload {
+			sess.Set(k, v)
+		}
+		size, err = sess.SizeBytes()
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, nil, sess.Save())
+
+		raw, _ := store.Storage.Get(id)
+		utils.AssertEqual(t, len(raw), size)
+	}
+
+	// the certificate binding and the expiration are included
+	store = New(Config{
+		BindClientCert:   true,
+		ExpirationHeader: "X-Expiration",
+		MaxTTL:           time.Hour,
+	})
+	store.fingerprint = func(c *fiber.Ctx) string {
+		return strings.Repeat("f", 64)
+	}
+	ctx.Request().Header.Set("X-Expiration", "30s")
+	sess, _ = store.Get(ctx)
+	id := sess.ID()
+	sess.Set("name", "john")
+	sess.Remember(30 * 24 * time.Hour)
+	size, err = sess.SizeBytes()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, sess.Save())
+	raw, _ := store.Storage.Get(id)
+	utils.AssertEqual(t, len(raw), size)
+}
+
 // go test -v -run=^$ -bench=Benchmark_Session -benchmem -count=4
 func Benchmark_Session(b *testing.B) {
 	app, store := fiber.New(), New()
@@ -1,31 +1,75 @@
 package session
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/internal/encoding/json"
 	"github.com/gofiber/fiber/v2/internal/storage/memory"
 	"github.com/gofiber/fiber/v2/utils"
 	"github.com/valyala/fasthttp"
 )
 
 type Store struct {
+	activeMu    sync.Mutex
+	active      map[string]time.Time      // storage keys of the sessions saved by this instance and their expiry
+	sweepAt     int                       // size of active that triggers the next sweep
+	fingerprint func(c *fiber.Ctx) string // client certificate fingerprint of a request
 	Config
 }
 
 var mux sync.Mutex
 
+// record is the encoded form of a session in the Storage. The values and
+// metadata of the handlers are kept apart from the timestamps and bindings
+// the middleware relies on, so handlers can't forge or overwrite them.
+type record struct {
+	Data       map[string]interface{}
+	Meta       map[string]string
+	Expires    int64  // unix time
+	Created    int64  // unix time
+	Rotated    int64  // unix time, only with a RotateInterval
+	SudoUntil  int64  // unix nano time, end of the elevated privileges
+	Remember   int64  // lifetime set by Remember, in nanoseconds
+	ClientCert string // fingerprint of the bound client certificate
+	Version    int
+}
+
+// ErrStorageUnreachable is returned by Ping if the Storage didn't return
+// the written sentinel value
+var ErrStorageUnreachable = errors.New("session: storage did not return the ping value")
+
+// pingKey prefixes the sentinel key written by Ping
+const pingKey = "__session_ping_"
+
+// DebugHeader is the response header DebugDump writes the session summary to
+const DebugHeader = "X-Session-Debug"
+
+func init() {
+	gob.Register([]interface{}{})
+}
+
 func New(config ...Config) *Store {
 	// Set default config
 	cfg := configDefault(config...)
 
 	if cfg.Storage == nil {
-		cfg.Storage = memory.New()
+		storage := memory.New()
+		if cfg.OnGC != nil {
+			storage.OnGC(cfg.OnGC)
+		}
+		cfg.Storage = storage
 	}
 
 	return &Store{
-		cfg,
+		fingerprint: clientCertFingerprint,
+		Config:      cfg,
 	}
 }
 
@@ -37,6 +81,22 @@ func (s *Store) RegisterType(i interface{}) {
 
 // Get will get/create a session
 func (s *Store) Get(c *fiber.Ctx) (*Session, error) {
+	sess, _, err := s.load(c)
+	return sess, err
+}
+
+// Load will get/create a session like Get and reports whether it is new,
+// i.e. its id was created during this request
+func (s *Store) Load(c *fiber.Ctx) (sess *Session, isNew bool, err error) {
+	if sess, isNew, err = s.load(c); err != nil {
+		return nil, false, err
+	}
+	return sess, isNew, nil
+}
+
+// load gets/creates a session and reports whether its id was created
+// during this request
+func (s *Store) load(c *fiber.Ctx) (*Session, bool, error) {
 	var fresh bool
 	var loadDada = true
 
@@ -47,16 +107,22 @@ func (s *Store) Get(c *fiber.Ctx) (*Session, error) {
 		fresh = true
 		var err error
 		if id, err = s.responseCookies(c); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
 	// If no key exist, create new one
 	if len(id) == 0 {
 		loadDada = false
-		id = s.KeyGenerator()
+		// Lazy sessions get their key with the first write
+		if !s.LazyCreate {
+			id = s.KeyGenerator()
+		}
 	}
 
+	// Ids that didn't come with the request were created during it
+	isNew := fresh
+
 	// Create session object
 	sess := acquireSession()
 	sess.ctx = c
@@ -66,25 +132,122 @@ func (s *Store) Get(c *fiber.Ctx) (*Session, error) {
 
 	// Fetch existing data
 	if loadDada {
-		raw, err := s.Storage.Get(id)
+		raw, err := s.Storage.Get(s.StorageKeyFunc(id))
 		// Unmashal if we found data
 		if raw != nil && err == nil {
 			mux.Lock()
 			defer mux.Unlock()
-			_, _ = sess.byteBuffer.Write(raw)
-			encCache := gob.NewDecoder(sess.byteBuffer)
-			err := encCache.Decode(&sess.data.Data)
+			version, err := sess.decode(raw)
 			if err != nil {
-				return nil, err
+				return nil, false, err
+			}
+			switch {
+			case sess.expired():
+				// The Storage didn't remove the expired data (yet)
+				s.untrack(s.StorageKeyFunc(id))
+				sess.reset()
+				sess.fresh = true
+			case s.boundElsewhere(sess, c):
+				// Sessions bound to another client certificate are neither
+				// handed out nor touched, the request gets a fresh session
+				sess.reset()
+				sess.id = s.KeyGenerator()
+				sess.fresh = true
+				isNew = true
+			default:
+				s.migrate(sess, version)
+				// The new id is handed out with the next Save
+				sess.rotateDue = s.RotateInterval > 0 && !sess.rotated.IsZero() && time.Since(sess.rotated) >= s.RotateInterval
 			}
 		} else if err != nil {
-			return nil, err
+			return nil, false, err
 		} else {
+			// The Storage removed the data, e.g. after the idle expiry
+			s.untrack(s.StorageKeyFunc(id))
 			sess.fresh = true
 		}
 	}
 
-	return sess, nil
+	return sess, isNew, nil
+}
+
+// Exists reports whether a session with the given id is present in the
+// Storage and not expired, only its expiry is decoded
+func (s *Store) Exists(id string) (bool, error) {
+	if len(id) == 0 {
+		return false, nil
+	}
+	raw, err := s.Storage.Get(s.StorageKeyFunc(id))
+	if err != nil || raw == nil {
+		return false, err
+	}
+	// the values are skipped, sessions saved as plain values have no expiry
+	var rec struct{ Expires int64 }
+	if gob.NewDecoder(bytes.NewReader(raw)).Decode(&rec) != nil || rec.Expires == 0 {
+		return true, nil
+	}
+	return time.Now().Before(time.Unix(rec.Expires, 0)), nil
+}
+
+// Save writes the data of the session with the given id directly to the
+// Storage, encoded the same way as Session.Save does. A ttl that isn't
+// positive falls back to the Expiration.
+func (s *Store) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = s.Expiration
+	}
+	sess := acquireSession()
+	defer releaseSession(sess)
+	sess.config = s
+	sess.id = id
+	sess.data.Replace(data)
+
+	mux.Lock()
+	defer mux.Unlock()
+	return sess.persist(ttl)
+}
+
+// migrate upgrades data stored with another DataVersion, the result is
+// saved with the next Save. The caller must hold the mux lock.
+func (s *Store) migrate(sess *Session, version int) {
+	if version == s.DataVersion {
+		return
+	}
+
+	// Start over if the data can't be migrated
+	if s.Migrate == nil {
+		sess.reset()
+		sess.fresh = true
+		return
+	}
+
+	data := s.Migrate(sess.data.Copy(), version)
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	sess.data.Lock()
+	sess.data.Data = data
+	sess.data.Unlock()
+}
+
+// boundElsewhere reports whether the session is bound to another client
+// certificate than the one of the request
+func (s *Store) boundElsewhere(sess *Session, c *fiber.Ctx) bool {
+	if !s.BindClientCert {
+		return false
+	}
+	return sess.clientCert != "" && sess.clientCert != s.fingerprint(c)
+}
+
+// clientCertFingerprint returns the SHA-256 fingerprint of the verified
+// client certificate, or an empty string without one
+func clientCertFingerprint(c *fiber.Ctx) string {
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:])
 }
 
 func (s *Store) responseCookies(c *fiber.Ctx) (string, error) {
@@ -107,7 +270,179 @@ func (s *Store) responseCookies(c *fiber.Ctx) (string, error) {
 	return id, nil
 }
 
+// debugSummary is written by DebugDump, it never contains session values
+type debugSummary struct {
+	ID        string         `json:"id"`
+	Fresh     bool           `json:"fresh"`
+	Keys      map[string]int `json:"keys"`
+	ExpiresIn int64          `json:"expires_in"`
+	Age       int64          `json:"age"`
+}
+
+// DebugDump creates a middleware handler that writes a JSON summary of the
+// session to the DebugHeader after the next handlers ran, if enabled returns
+// true for the request and it carries a session cookie. The summary holds
+// the id, the encoded size of every key and the expiry and age in seconds,
+// but no values. The session is only read, never created or written. A nil
+// enabled never activates the handler, gate it on a development flag, never
+// on the request alone.
+func (s *Store) DebugDump(enabled func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if enabled == nil || !enabled(c) {
+			return nil
+		}
+
+		// Prefer the cookie set by the handlers over the one of the request
+		id, err := s.responseCookies(c)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			id = c.Cookies(s.CookieName)
+		}
+		if id == "" {
+			return nil
+		}
+
+		sess, err := s.peek(c, id)
+		if err != nil {
+			return err
+		}
+		defer releaseSession(sess)
+		summary := debugSummary{
+			ID:        sess.ID(),
+			Fresh:     sess.Fresh(),
+			Keys:      make(map[string]int),
+			ExpiresIn: int64(sess.ExpiresIn() / time.Second),
+			Age:       int64(sess.Age() / time.Second),
+		}
+		var buf bytes.Buffer
+		for key, value := range sess.Values() {
+			buf.Reset()
+			// -1 marks values that can't be encoded
+			summary.Keys[key] = -1
+			if gob.NewEncoder(&buf).Encode(&value) == nil {
+				summary.Keys[key] = buf.Len()
+			}
+		}
+
+		raw, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		c.Set(DebugHeader, utils.UnsafeString(raw))
+		return nil
+	}
+}
+
+// peek reads the stored session with the given id like Get, but without
+// migrating, rotating or counting it. Sessions that are expired or bound
+// to another client certificate are reported as fresh and empty. The
+// caller has to release the session.
+func (s *Store) peek(c *fiber.Ctx, id string) (*Session, error) {
+	sess := acquireSession()
+	sess.ctx = c
+	sess.config = s
+	sess.id = id
+
+	raw, err := s.Storage.Get(s.StorageKeyFunc(id))
+	if err != nil || raw == nil {
+		return sess, err
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if _, err := sess.decode(raw); err != nil {
+		releaseSession(sess)
+		return nil, err
+	}
+	if sess.expired() || s.boundElsewhere(sess, c) {
+		sess.reset()
+		return sess, nil
+	}
+	sess.fresh = false
+	return sess, nil
+}
+
+// Ping verifies that the Storage is reachable, e.g. for readiness probes.
+// Storages with a Ping() error method are asked directly, otherwise a
+// sentinel key is written, read back and deleted.
+func (s *Store) Ping() error {
+	if pinger, ok := s.Storage.(interface{ Ping() error }); ok {
+		return pinger.Ping()
+	}
+
+	key := pingKey + s.KeyGenerator()
+	value := []byte(key)
+	if err := s.Storage.Set(key, value, time.Minute); err != nil {
+		return err
+	}
+	raw, err := s.Storage.Get(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(raw, value) {
+		return ErrStorageUnreachable
+	}
+	return s.Storage.Delete(key)
+}
+
+// ActiveCount returns the number of live sessions saved by this store
+// instance. It is an in-process approximation, other instances sharing
+// the same Storage are not taken into account. Sessions are uncounted
+// once they pass their expiry, when destroyed or expired with ExpireNow,
+// or when the Storage no longer holds them.
+func (s *Store) ActiveCount() int64 {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	s.sweep()
+	return int64(len(s.active))
+}
+
+// track counts the session saved under the key until it expires, saving
+// it again only updates the expiry
+func (s *Store) track(key string, expires time.Time) {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	if s.active == nil {
+		s.active = make(map[string]time.Time)
+	}
+	s.active[key] = expires
+	// Sweep once the map doubled, so sessions that are never loaded again
+	// don't pile up
+	if len(s.active) >= s.sweepAt {
+		s.sweep()
+		s.sweepAt = 2*len(s.active) + 64
+	}
+}
+
+// untrack stops counting the session saved under the key, it's a no-op
+// for sessions that aren't counted
+func (s *Store) untrack(key string) {
+	s.activeMu.Lock()
+	delete(s.active, key)
+	s.activeMu.Unlock()
+}
+
+// sweep drops the expired sessions, the caller must hold the activeMu lock
+func (s *Store) sweep() {
+	now := time.Now()
+	for key, expires := range s.active {
+		if !now.Before(expires) {
+			delete(s.active, key)
+		}
+	}
+}
+
 // Reset will delete all session from the storage
 func (s *Store) Reset() error {
-	return s.Storage.Reset()
+	if err := s.Storage.Reset(); err != nil {
+		return err
+	}
+	s.activeMu.Lock()
+	s.active = nil
+	s.activeMu.Unlock()
+	return nil
 }